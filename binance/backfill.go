@@ -0,0 +1,221 @@
+package binance
+
+import (
+	"binance-monitor/models"
+	"binance-monitor/strategy"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// klinesPageSize is the backfill page size of /fapi/v1/klines. 1000 klines
+	// cost weight 5, the lowest weight per kline of all page sizes.
+	klinesPageSize = 1000
+	// statsPageSize is the maximum page size of the /futures/data endpoints.
+	statsPageSize = 500
+	// StatsHistory is how far back the /futures/data statistics endpoints
+	// reach; older ranges are clamped.
+	StatsHistory = 30 * 24 * time.Hour
+)
+
+// statsEndpoints are the statistics series fetched by Backfill, in the order
+// their failures are reported.
+var statsEndpoints = []string{
+	"/futures/data/openInterestHist",
+	"/futures/data/globalLongShortAccountRatio",
+	"/futures/data/takerlongshortRatio",
+	"/futures/data/topLongShortAccountRatio",
+	"/futures/data/topLongShortPositionRatio",
+}
+
+// Backfill fetches the klines and statistics series of a symbol between start
+// and end, however long the range, by walking startTime/endTime windows of at
+// most one page each. Overlapping pages are de-duplicated by timestamp.
+//
+// Statistics are only kept for the last StatsHistory before the client's
+// clock (see SetClock), so their range is clamped; failures of the optional
+// statistics are recorded in Warnings like FetchMarketData does. The whole
+// backfill is checked against the remaining weight budget before the first
+// request.
+//
+// Series are returned as the API reports them, oldest first and including the
+// live kline, so they can be stored as-is; align them with
+// strategy.AlignMarketData before analysis.
+func (c *Client) Backfill(symbol, interval string, start, end time.Time) (strategy.MarketData, error) {
+	data := strategy.MarketData{Symbol: symbol, Exchange: Exchange}
+
+	period, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return data, err
+	}
+	if !start.Before(end) {
+		return data, fmt.Errorf("invalid backfill range %s - %s", start, end)
+	}
+	statsStart := c.statsStart(start, period)
+
+	klinePages := pageCount(start, end, period, klinesPageSize)
+	statsPages := 0
+	if statsStart.Before(end) {
+		statsPages = pageCount(statsStart, end, period, statsPageSize)
+	}
+	weight := klinePages*klinesWeight(klinesPageSize) + statsPages*statsWeight*len(statsEndpoints)
+	if remaining := c.RemainingWeight(); remaining >= 0 && weight > remaining {
+		return data, fmt.Errorf("backfill of %s needs weight %d, %d left: %w", symbol, weight, remaining, ErrWeightBudgetExceeded)
+	}
+
+	data.Klines, err = c.KlinesRange(symbol, interval, start, end)
+	if err != nil {
+		return data, fmt.Errorf("failed to backfill klines: %w", err)
+	}
+
+	if statsPages > 0 {
+		errs := make([]error, len(statsEndpoints))
+		data.OIs, errs[0] = c.OpenInterestRange(symbol, interval, start, end)
+		data.LSRatios, errs[1] = c.LongShortRatioRange(symbol, interval, start, end)
+		data.TakerRatios, errs[2] = c.TakerLongShortRatioRange(symbol, interval, start, end)
+		data.TopAccountRatios, errs[3] = c.TopLongShortAccountRatioRange(symbol, interval, start, end)
+		data.TopPositionRatios, errs[4] = c.TopLongShortPositionRatioRange(symbol, interval, start, end)
+		for i, err := range errs {
+			if err != nil {
+				data.Warnings = append(data.Warnings, fmt.Sprintf("failed to backfill %s: %v", statsEndpoints[i], err))
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// KlinesRange fetches the klines of a symbol opened within [start, end],
+// oldest first, in pages of klinesPageSize. A kline that is still forming is
+// included.
+func (c *Client) KlinesRange(symbol, interval string, start, end time.Time) ([]models.KlineData, error) {
+	period, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	return walkRange(start, end, period, klinesPageSize, func(from, to int64) ([]models.KlineData, error) {
+		body, err := c.get("/fapi/v1/klines", url.Values{
+			"symbol":    {symbol},
+			"interval":  {interval},
+			"startTime": {strconv.FormatInt(from, 10)},
+			"endTime":   {strconv.FormatInt(to, 10)},
+			"limit":     {strconv.Itoa(klinesPageSize)},
+		}, klinesWeight(klinesPageSize))
+		if err != nil {
+			return nil, err
+		}
+		return decodeKlines(body, symbol)
+	}, func(k models.KlineData) int64 { return k.Timestamp })
+}
+
+// OpenInterestRange fetches the open interest history within [start, end],
+// clamped to the last StatsHistory.
+func (c *Client) OpenInterestRange(symbol, period string, start, end time.Time) ([]models.BinanceOI, error) {
+	return statsRange(c, statsEndpoints[0], symbol, period, start, end, func(p models.BinanceOI) int64 { return p.Timestamp })
+}
+
+// LongShortRatioRange fetches the long/short account ratio history within
+// [start, end], clamped to the last StatsHistory.
+func (c *Client) LongShortRatioRange(symbol, period string, start, end time.Time) ([]models.GlobalLongShortRatio, error) {
+	return statsRange(c, statsEndpoints[1], symbol, period, start, end, func(p models.GlobalLongShortRatio) int64 { return p.Timestamp })
+}
+
+// TakerLongShortRatioRange fetches the taker buy/sell ratio history within
+// [start, end], clamped to the last StatsHistory.
+func (c *Client) TakerLongShortRatioRange(symbol, period string, start, end time.Time) ([]models.TakerLongShortRatio, error) {
+	return statsRange(c, statsEndpoints[2], symbol, period, start, end, func(p models.TakerLongShortRatio) int64 { return p.Timestamp })
+}
+
+// TopLongShortAccountRatioRange fetches the top trader account ratio history
+// within [start, end], clamped to the last StatsHistory.
+func (c *Client) TopLongShortAccountRatioRange(symbol, period string, start, end time.Time) ([]models.TopLongShortRatio, error) {
+	return statsRange(c, statsEndpoints[3], symbol, period, start, end, func(p models.TopLongShortRatio) int64 { return p.Timestamp })
+}
+
+// TopLongShortPositionRatioRange fetches the top trader position ratio
+// history within [start, end], clamped to the last StatsHistory.
+func (c *Client) TopLongShortPositionRatioRange(symbol, period string, start, end time.Time) ([]models.TopLongShortRatio, error) {
+	return statsRange(c, statsEndpoints[4], symbol, period, start, end, func(p models.TopLongShortRatio) int64 { return p.Timestamp })
+}
+
+// statsStart clamps the start of a statistics range to the oldest period the
+// endpoints still serve.
+func (c *Client) statsStart(start time.Time, period time.Duration) time.Time {
+	if oldest := c.now().Add(-StatsHistory + period); start.Before(oldest) {
+		return oldest
+	}
+	return start
+}
+
+// statsRange walks a statistics endpoint over [start, end], with start
+// clamped by statsStart. A range entirely older than StatsHistory is empty.
+func statsRange[T any](c *Client, path, symbol, period string, start, end time.Time, timestamp func(T) int64) ([]T, error) {
+	step, err := strategy.ParseInterval(period)
+	if err != nil {
+		return nil, err
+	}
+	if start = c.statsStart(start, step); !start.Before(end) {
+		return nil, nil
+	}
+	return walkRange(start, end, step, statsPageSize, func(from, to int64) ([]T, error) {
+		body, err := c.get(path, url.Values{
+			"symbol":    {symbol},
+			"period":    {period},
+			"startTime": {strconv.FormatInt(from, 10)},
+			"endTime":   {strconv.FormatInt(to, 10)},
+			"limit":     {strconv.Itoa(statsPageSize)},
+		}, statsWeight)
+		if err != nil {
+			return nil, err
+		}
+
+		var page []T
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+		}
+		return page, nil
+	}, timestamp)
+}
+
+// walkRange fetches [start, end] in consecutive windows spanning pageSize
+// periods each, so no window can hold more points than one page, whichever
+// end of the window the API trims from. Points outside the range and
+// duplicates of overlapping pages are dropped; the result is sorted by time.
+func walkRange[T any](start, end time.Time, period time.Duration, pageSize int, fetch func(from, to int64) ([]T, error), timestamp func(T) int64) ([]T, error) {
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+	span := period.Milliseconds() * int64(pageSize)
+
+	seen := map[int64]bool{}
+	var points []T
+	for from := startMs; from <= endMs; from += span {
+		to := from + span - 1
+		if to > endMs {
+			to = endMs
+		}
+		page, err := fetch(from, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range page {
+			ts := timestamp(p)
+			if ts < startMs || ts > endMs || seen[ts] {
+				continue
+			}
+			seen[ts] = true
+			points = append(points, p)
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool { return timestamp(points[i]) < timestamp(points[j]) })
+	return points, nil
+}
+
+// pageCount returns the number of windows walkRange requests for a range.
+func pageCount(start, end time.Time, period time.Duration, pageSize int) int {
+	span := period.Milliseconds() * int64(pageSize)
+	return int((end.UnixMilli()-start.UnixMilli())/span) + 1
+}
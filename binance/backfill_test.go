@@ -0,0 +1,48 @@
+package binance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackfillStatsRangeFollowsClock(t *testing.T) {
+	var mu sync.Mutex
+	statsStart := map[string]int64{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fapi/v1/klines" {
+			from, _ := strconv.ParseInt(r.URL.Query().Get("startTime"), 10, 64)
+			mu.Lock()
+			if prev, ok := statsStart[r.URL.Path]; !ok || from < prev {
+				statsStart[r.URL.Path] = from
+			}
+			mu.Unlock()
+		}
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	// A replayed run a year ago: with the wall clock, the whole range would
+	// be older than StatsHistory and no statistics would be requested.
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := NewClient(srv.Client(), srv.URL)
+	c.SetClock(func() time.Time { return now })
+
+	if _, err := c.Backfill("BTCUSDT", "1h", now.Add(-60*24*time.Hour), now); err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	want := now.Add(-StatsHistory + time.Hour).UnixMilli()
+	for _, path := range statsEndpoints {
+		got, ok := statsStart[path]
+		if !ok {
+			t.Errorf("%s was not requested", path)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s starts at %d, want %d", path, got, want)
+		}
+	}
+}
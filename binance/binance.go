@@ -0,0 +1,343 @@
+// Package binance implements strategy.MarketDataSource on top of the
+// Binance USDⓈ-M futures REST API.
+package binance
+
+import (
+	"binance-monitor/models"
+	"binance-monitor/strategy"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBaseURL is the production USDⓈ-M futures endpoint.
+const DefaultBaseURL = "https://fapi.binance.com"
+
+// DefaultSpotBaseURL is the production spot endpoint used for SpotKlines.
+const DefaultSpotBaseURL = "https://api.binance.com"
+
+// Exchange is the venue name reported by the Client.
+const Exchange = "binance"
+
+var (
+	_ strategy.MarketDataSource  = (*Client)(nil)
+	_ strategy.TakerVolumeSource = (*Client)(nil)
+	_ strategy.TopTraderSource   = (*Client)(nil)
+	_ strategy.FundingSource     = (*Client)(nil)
+	_ strategy.DepthSource       = (*Client)(nil)
+	_ strategy.BasisSource       = (*Client)(nil)
+	_ strategy.SpotSource        = (*Client)(nil)
+	_ strategy.WeightBudget      = (*Client)(nil)
+)
+
+// Client fetches market data from a Binance USDⓈ-M compatible API.
+//
+// A Client is safe for concurrent use. It tracks the request weight reported
+// by the API and the weight spent against its own budget, so one Client
+// should be shared by all symbols of a run.
+type Client struct {
+	baseURL     string
+	spotBaseURL string
+	httpClient  *http.Client
+
+	mu           sync.Mutex
+	usedWeight1m int       // last X-MBX-USED-WEIGHT-1m reported by the API
+	usedWeightAt time.Time // when usedWeight1m was reported
+	budget       int       // max weight this client may spend, 0 = unlimited
+	spent        int       // weight spent against budget
+	symbols      []SymbolInfo
+	symbolsAt    time.Time // when symbols was fetched
+	now          func() time.Time
+
+	// infoMu serializes ExchangeInfo, so workers hitting a cold cache at the
+	// same time share one request instead of each fetching the full list.
+	infoMu sync.Mutex
+}
+
+// NewClient creates a new Client. A nil httpClient falls back to
+// http.DefaultClient and an empty baseURL to DefaultBaseURL, so the client
+// can be pointed at a proxy, the testnet or an httptest server.
+func NewClient(httpClient *http.Client, baseURL string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		spotBaseURL: DefaultSpotBaseURL,
+		httpClient:  httpClient,
+		now:         time.Now,
+	}
+}
+
+// SetClock sets the clock Backfill clamps the statistics history with, so a
+// backfill agrees with the clock the data is aligned and stored with (see
+// strategy.AlignOptions and store.Source.SetClock). It defaults to time.Now.
+// Rate limiting always follows the wall clock, like the API does.
+func (c *Client) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+// SetSpotBaseURL points SpotKlines at a different spot API, e.g. a proxy.
+// An empty baseURL restores DefaultSpotBaseURL.
+func (c *Client) SetSpotBaseURL(baseURL string) {
+	if baseURL == "" {
+		baseURL = DefaultSpotBaseURL
+	}
+	c.spotBaseURL = strings.TrimRight(baseURL, "/")
+}
+
+// Exchange implements strategy.MarketDataSource.
+func (c *Client) Exchange() string {
+	return Exchange
+}
+
+// Klines fetches the most recent klines for a symbol.
+func (c *Client) Klines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	body, err := c.get("/fapi/v1/klines", url.Values{
+		"symbol":   {symbol},
+		"interval": {interval},
+		"limit":    {strconv.Itoa(limit)},
+	}, klinesWeight(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeKlines(body, symbol)
+}
+
+// SpotKlines fetches the most recent klines of the spot market with the same
+// symbol. Perpetuals without a spot market of that name (e.g. 1000PEPEUSDT)
+// fail with the API's invalid symbol error.
+func (c *Client) SpotKlines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	body, err := c.getFrom(c.spotBaseURL, "/api/v3/klines", url.Values{
+		"symbol":   {symbol},
+		"interval": {interval},
+		"limit":    {strconv.Itoa(limit)},
+	}, spotKlinesWeight)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeKlines(body, symbol)
+}
+
+// decodeKlines decodes a kline array response into KlineData. Every field is
+// validated: a malformed row fails the whole response with an error naming
+// the row and field instead of panicking or silently yielding zeros.
+func decodeKlines(body []byte, symbol string) ([]models.KlineData, error) {
+	var rawKlines []models.BinanceKline
+	if err := json.Unmarshal(body, &rawKlines); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	}
+
+	klines := make([]models.KlineData, 0, len(rawKlines))
+	for i, k := range rawKlines {
+		kline, err := parseKline(k, symbol)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kline %d of %s: %w", i, symbol, err)
+		}
+		klines = append(klines, kline)
+	}
+	return klines, nil
+}
+
+// parseKline validates and converts a single raw kline row. Times and the
+// trade count are JSON integers, prices and volumes decimal strings.
+func parseKline(k models.BinanceKline, symbol string) (models.KlineData, error) {
+	if len(k) < 11 {
+		return models.KlineData{}, fmt.Errorf("expected at least 11 fields, got %d", len(k))
+	}
+
+	var ints [3]int64
+	for j, field := range []int{0, 6, 8} {
+		if err := json.Unmarshal(k[field], &ints[j]); err != nil {
+			return models.KlineData{}, fmt.Errorf("field %d: %w", field, err)
+		}
+	}
+
+	var floats [8]float64
+	for j, field := range []int{1, 2, 3, 4, 5, 7, 9, 10} {
+		var s string
+		if err := json.Unmarshal(k[field], &s); err != nil {
+			return models.KlineData{}, fmt.Errorf("field %d: %w", field, err)
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return models.KlineData{}, fmt.Errorf("field %d: %w", field, err)
+		}
+		floats[j] = v
+	}
+
+	kline := models.KlineData{
+		Symbol:      symbol,
+		Timestamp:   ints[0],
+		CloseTime:   ints[1],
+		TradeCount:  ints[2],
+		Open:        floats[0],
+		High:        floats[1],
+		Low:         floats[2],
+		Close:       floats[3],
+		Volume:      floats[4],
+		QuoteVolume: floats[5],
+
+		TakerBuyVolume:      floats[6],
+		TakerBuyQuoteVolume: floats[7],
+	}
+	if kline.CloseTime < kline.Timestamp {
+		return models.KlineData{}, fmt.Errorf("close time %d before open time %d", kline.CloseTime, kline.Timestamp)
+	}
+	return kline, nil
+}
+
+// OpenInterest fetches the open interest history for a symbol.
+func (c *Client) OpenInterest(symbol, period string, limit int) ([]models.BinanceOI, error) {
+	body, err := c.get("/futures/data/openInterestHist", statsParams(symbol, period, limit), statsWeight)
+	if err != nil {
+		return nil, err
+	}
+
+	var ois []models.BinanceOI
+	if err := json.Unmarshal(body, &ois); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	}
+	return ois, nil
+}
+
+// LongShortRatio fetches the global long/short account ratio history for a symbol.
+func (c *Client) LongShortRatio(symbol, period string, limit int) ([]models.GlobalLongShortRatio, error) {
+	body, err := c.get("/futures/data/globalLongShortAccountRatio", statsParams(symbol, period, limit), statsWeight)
+	if err != nil {
+		return nil, err
+	}
+
+	var ratios []models.GlobalLongShortRatio
+	if err := json.Unmarshal(body, &ratios); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	}
+	return ratios, nil
+}
+
+// TopLongShortAccountRatio fetches the top trader long/short ratio by account count.
+func (c *Client) TopLongShortAccountRatio(symbol, period string, limit int) ([]models.TopLongShortRatio, error) {
+	return c.topLongShortRatio("/futures/data/topLongShortAccountRatio", symbol, period, limit)
+}
+
+// TopLongShortPositionRatio fetches the top trader long/short ratio by position size.
+func (c *Client) TopLongShortPositionRatio(symbol, period string, limit int) ([]models.TopLongShortRatio, error) {
+	return c.topLongShortRatio("/futures/data/topLongShortPositionRatio", symbol, period, limit)
+}
+
+func (c *Client) topLongShortRatio(path, symbol, period string, limit int) ([]models.TopLongShortRatio, error) {
+	body, err := c.get(path, statsParams(symbol, period, limit), statsWeight)
+	if err != nil {
+		return nil, err
+	}
+
+	var ratios []models.TopLongShortRatio
+	if err := json.Unmarshal(body, &ratios); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	}
+	return ratios, nil
+}
+
+// TakerLongShortRatio fetches the taker buy/sell volume ratio history for a symbol.
+func (c *Client) TakerLongShortRatio(symbol, period string, limit int) ([]models.TakerLongShortRatio, error) {
+	body, err := c.get("/futures/data/takerlongshortRatio", statsParams(symbol, period, limit), statsWeight)
+	if err != nil {
+		return nil, err
+	}
+
+	var ratios []models.TakerLongShortRatio
+	if err := json.Unmarshal(body, &ratios); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	}
+	return ratios, nil
+}
+
+// FundingRates fetches the most recent settled funding rates for a symbol.
+func (c *Client) FundingRates(symbol string, limit int) ([]models.FundingRate, error) {
+	body, err := c.get("/fapi/v1/fundingRate", url.Values{
+		"symbol": {symbol},
+		"limit":  {strconv.Itoa(limit)},
+	}, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []models.FundingRate
+	if err := json.Unmarshal(body, &rates); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	}
+	return rates, nil
+}
+
+// PremiumIndex fetches the mark price, index price and predicted funding rate for a symbol.
+func (c *Client) PremiumIndex(symbol string) (*models.PremiumIndex, error) {
+	body, err := c.get("/fapi/v1/premiumIndex", url.Values{"symbol": {symbol}}, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var index models.PremiumIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	}
+	return &index, nil
+}
+
+// Depth fetches an order book snapshot with limit levels per side.
+func (c *Client) Depth(symbol string, limit int) (*models.OrderBook, error) {
+	body, err := c.get("/fapi/v1/depth", url.Values{
+		"symbol": {symbol},
+		"limit":  {strconv.Itoa(limit)},
+	}, depthWeight(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	var raw models.BinanceDepth
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	}
+
+	book := &models.OrderBook{Symbol: symbol, Timestamp: raw.TransactTime}
+	if book.Bids, err = parseLevels(raw.Bids); err != nil {
+		return nil, fmt.Errorf("invalid bid level: %w", err)
+	}
+	if book.Asks, err = parseLevels(raw.Asks); err != nil {
+		return nil, fmt.Errorf("invalid ask level: %w", err)
+	}
+	return book, nil
+}
+
+func parseLevels(raw [][2]string) ([]models.OrderBookLevel, error) {
+	levels := make([]models.OrderBookLevel, 0, len(raw))
+	for _, l := range raw {
+		price, err := strconv.ParseFloat(l[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		qty, err := strconv.ParseFloat(l[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, models.OrderBookLevel{Price: price, Quantity: qty})
+	}
+	return levels, nil
+}
+
+func statsParams(symbol, period string, limit int) url.Values {
+	return url.Values{
+		"symbol": {symbol},
+		"period": {period},
+		"limit":  {strconv.Itoa(limit)},
+	}
+}
@@ -0,0 +1,371 @@
+package binance
+
+import (
+	"binance-monitor/models"
+	"binance-monitor/strategy"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeAPI serves synthetic klines and statistics for any symbol. The newest
+// kline opens at live and is still forming; statistics are snapshots taken at
+// the close of every period. Endpoints without a handler answer like Binance
+// does for an unknown symbol.
+type fakeAPI struct {
+	period time.Duration
+	live   time.Time
+	// volume returns the volume of the kline opened at ts.
+	volume func(ts int64) float64
+	// status overrides the response status of a path.
+	status map[string]int
+
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+func newFakeAPI(period time.Duration) *fakeAPI {
+	live := time.Now().Truncate(period)
+	return &fakeAPI{
+		period: period,
+		live:   live,
+		volume: func(ts int64) float64 { return 100 + float64(ts/period.Milliseconds()%5) },
+		status: map[string]int{},
+		hits:   map[string]int{},
+	}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.hits[r.URL.Path]++
+	status := f.status[r.URL.Path]
+	f.mu.Unlock()
+
+	if status != 0 {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"code":-1003,"msg":"status %d"}`, status)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	symbol := r.URL.Query().Get("symbol")
+	switch {
+	case r.URL.Path == "/fapi/v1/klines" || r.URL.Path == "/api/v3/klines":
+		json.NewEncoder(w).Encode(f.klines(limit))
+	case strings.HasPrefix(r.URL.Path, "/futures/data/"):
+		json.NewEncoder(w).Encode(f.stats(symbol, limit))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"code":-1121,"msg":"Invalid symbol."}`)
+	}
+}
+
+// klines returns the newest limit raw kline rows, the last one live.
+func (f *fakeAPI) klines(limit int) [][]interface{} {
+	step := f.period.Milliseconds()
+	rows := make([][]interface{}, 0, limit)
+	for i := limit - 1; i >= 0; i-- {
+		open := f.live.UnixMilli() - int64(i)*step
+		v := f.volume(open)
+		price := strconv.FormatFloat(100+float64(open/step%7), 'f', 2, 64)
+		rows = append(rows, []interface{}{
+			open, price, price, price, price, strconv.FormatFloat(v, 'f', 2, 64),
+			open + step - 1, strconv.FormatFloat(v*100, 'f', 2, 64), 10,
+			strconv.FormatFloat(v/2, 'f', 2, 64), strconv.FormatFloat(v*50, 'f', 2, 64), "0",
+		})
+	}
+	return rows
+}
+
+// stats returns the newest limit statistics snapshots, the last one taken at
+// the close of the newest closed kline. The fields of every statistics
+// endpoint are filled so one response fits all of them.
+func (f *fakeAPI) stats(symbol string, limit int) []map[string]interface{} {
+	step := f.period.Milliseconds()
+	points := make([]map[string]interface{}, 0, limit)
+	for i := limit - 1; i >= 0; i-- {
+		ts := f.live.UnixMilli() - int64(i)*step
+		points = append(points, map[string]interface{}{
+			"symbol":               symbol,
+			"sumOpenInterest":      "1000",
+			"sumOpenInterestValue": "100000",
+			"longShortRatio":       "1.0",
+			"longAccount":          "0.5",
+			"shortAccount":         "0.5",
+			"buySellRatio":         "1.0",
+			"buyVol":               "10",
+			"sellVol":              "10",
+			"timestamp":            ts,
+		})
+	}
+	return points
+}
+
+func (f *fakeAPI) hitCount(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hits[path]
+}
+
+// newTestClient starts f and returns a client pointed at it for both the
+// futures and the spot API.
+func newTestClient(t *testing.T, f *fakeAPI) *Client {
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.Client(), srv.URL)
+	c.SetSpotBaseURL(srv.URL)
+	return c
+}
+
+func TestFetchMarketData(t *testing.T) {
+	api := newFakeAPI(15 * time.Minute)
+	c := newTestClient(t, api)
+
+	data, err := strategy.FetchMarketData(c, "BTCUSDT", "15m", 96, strategy.DefaultAlignOptions())
+	if err != nil {
+		t.Fatalf("FetchMarketData: %v", err)
+	}
+	if data.Exchange != Exchange {
+		t.Errorf("Exchange = %q, want %q", data.Exchange, Exchange)
+	}
+	if len(data.Klines) != 96 {
+		t.Fatalf("got %d closed klines, want 96", len(data.Klines))
+	}
+	last := data.Klines[len(data.Klines)-1]
+	if want := api.live.Add(-api.period).UnixMilli(); last.Timestamp != want {
+		t.Errorf("newest closed kline opens at %d, want %d", last.Timestamp, want)
+	}
+	if data.LiveKline == nil || data.LiveKline.Timestamp != api.live.UnixMilli() {
+		t.Errorf("LiveKline = %+v, want the kline opened at %d", data.LiveKline, api.live.UnixMilli())
+	}
+	for name, n := range map[string]int{
+		"open interest":    len(data.OIs),
+		"long/short ratio": len(data.LSRatios),
+		"taker ratio":      len(data.TakerRatios),
+		"spot klines":      len(data.SpotKlines),
+	} {
+		if n == 0 {
+			t.Errorf("%s: no data", name)
+		}
+	}
+	if got := data.OIs[len(data.OIs)-1].Timestamp; got != last.Timestamp {
+		t.Errorf("newest open interest aligned to %d, want %d", got, last.Timestamp)
+	}
+	// Depth, funding and basis are not served: they fail as optional series.
+	if len(data.Warnings) == 0 {
+		t.Error("expected warnings for the unsupported optional endpoints")
+	}
+}
+
+func TestFetchMarketDataRequiredSeriesFails(t *testing.T) {
+	api := newFakeAPI(15 * time.Minute)
+	api.status["/futures/data/openInterestHist"] = http.StatusBadRequest
+	c := newTestClient(t, api)
+
+	_, err := strategy.FetchMarketData(c, "BTCUSDT", "15m", 96, strategy.DefaultAlignOptions())
+	if err == nil || !strings.Contains(err.Error(), "open interest") {
+		t.Fatalf("err = %v, want a failed open interest error", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("err = %v, want a wrapped *APIError with status 400", err)
+	}
+}
+
+func TestAnalyzeVolumeSpike(t *testing.T) {
+	api := newFakeAPI(15 * time.Minute)
+	spike := api.live.Add(-api.period).UnixMilli()
+	base := api.volume
+	api.volume = func(ts int64) float64 {
+		if ts == spike {
+			return 5000
+		}
+		return base(ts)
+	}
+	c := newTestClient(t, api)
+
+	data, err := strategy.FetchMarketData(c, "BTCUSDT", "15m", 96, strategy.DefaultAlignOptions())
+	if err != nil {
+		t.Fatalf("FetchMarketData: %v", err)
+	}
+	var volume *models.Signal
+	for _, s := range strategy.Analyze(data) {
+		if s.SignalType == models.VolumeSignal {
+			s := s
+			volume = &s
+		}
+	}
+	if volume == nil {
+		t.Fatal("no volume signal for a 50x volume spike")
+	}
+	if volume.Exchange != Exchange || volume.Symbol != "BTCUSDT" {
+		t.Errorf("signal is for %s on %s, want BTCUSDT on %s", volume.Symbol, volume.Exchange, Exchange)
+	}
+	if got := volume.Timestamp.UnixMilli(); got != spike {
+		t.Errorf("signal timestamp %d, want the spike kline %d", got, spike)
+	}
+}
+
+func TestFetchMarketDataSkipsOptionalSeriesOnLowBudget(t *testing.T) {
+	api := newFakeAPI(15 * time.Minute)
+	c := newTestClient(t, api)
+	required, optional := c.FetchWeight(97)
+	c.SetWeightBudget(required + optional - 1)
+
+	data, err := strategy.FetchMarketData(c, "BTCUSDT", "15m", 96, strategy.DefaultAlignOptions())
+	if err != nil {
+		t.Fatalf("FetchMarketData: %v", err)
+	}
+	if len(data.Klines) != 96 || len(data.OIs) == 0 || len(data.LSRatios) == 0 {
+		t.Errorf("required series missing: %d klines, %d OIs, %d ratios", len(data.Klines), len(data.OIs), len(data.LSRatios))
+	}
+	if got := api.hitCount("/fapi/v1/depth") + api.hitCount("/api/v3/klines"); got != 0 {
+		t.Errorf("optional endpoints requested %d times, want 0", got)
+	}
+	if len(data.Warnings) != 1 || !strings.Contains(data.Warnings[0], "skipped") {
+		t.Errorf("Warnings = %q, want one about skipped optional series", data.Warnings)
+	}
+	if got := c.WeightSpent(); got != required {
+		t.Errorf("WeightSpent = %d, want only the required %d", got, required)
+	}
+}
+
+func TestAnalyzeSymbolsReservesRequiredWeight(t *testing.T) {
+	api := newFakeAPI(15 * time.Minute)
+	c := newTestClient(t, api)
+	required, optional := c.FetchWeight(97)
+	// Enough for one full symbol, but then the second could not fetch its
+	// required series: the first must leave its optional series out.
+	c.SetWeightBudget(2*required + optional - 1)
+
+	results := strategy.AnalyzeSymbols([]strategy.Target{
+		{Symbol: "BTCUSDT", Source: c},
+		{Symbol: "ETHUSDT", Source: c},
+	}, "15m", 96, 1, strategy.DefaultAlignOptions())
+	for _, res := range results {
+		if res.Err != nil {
+			t.Errorf("%s: %v", res.Symbol, res.Err)
+		}
+	}
+}
+
+func TestExchangeInfoSingleRequest(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, `{"symbols":[{"symbol":"BTCUSDT","pair":"BTCUSDT","contractType":"PERPETUAL","status":"TRADING"}]}`)
+	}))
+	defer srv.Close()
+	c := NewClient(srv.Client(), srv.URL)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if symbols, err := c.ExchangeInfo(); err != nil || len(symbols) != 1 {
+				t.Errorf("ExchangeInfo = %v, %v", symbols, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("exchangeInfo requested %d times, want 1", got)
+	}
+}
+
+func TestDecodeKlines(t *testing.T) {
+	const valid = `[1700000000000,"100.5","101","99.5","100.25","12.5",1700000899999,"1253.1",42,"6.25","626.5","0"]`
+	tests := []struct {
+		name    string
+		body    string
+		want    []models.KlineData
+		wantErr string
+	}{
+		{
+			name: "valid",
+			body: "[" + valid + "]",
+			want: []models.KlineData{{
+				Symbol:              "BTCUSDT",
+				Timestamp:           1700000000000,
+				CloseTime:           1700000899999,
+				TradeCount:          42,
+				Open:                100.5,
+				High:                101,
+				Low:                 99.5,
+				Close:               100.25,
+				Volume:              12.5,
+				QuoteVolume:         1253.1,
+				TakerBuyVolume:      6.25,
+				TakerBuyQuoteVolume: 626.5,
+			}},
+		},
+		{name: "empty", body: "[]", want: []models.KlineData{}},
+		{name: "not an array", body: `{"code":-1121}`, wantErr: "json unmarshal error"},
+		{
+			name:    "too few fields",
+			body:    `[[1700000000000,"100.5","101","99.5","100.25","12.5",1700000899999,"1253.1",42,"6.25"]]`,
+			wantErr: "invalid kline 0 of BTCUSDT: expected at least 11 fields, got 10",
+		},
+		{
+			name:    "price as number",
+			body:    `[[1700000000000,100.5,"101","99.5","100.25","12.5",1700000899999,"1253.1",42,"6.25","626.5"]]`,
+			wantErr: "field 1",
+		},
+		{
+			name:    "unparsable price",
+			body:    `[[1700000000000,"100.5","101","99.5","NaN?","12.5",1700000899999,"1253.1",42,"6.25","626.5"]]`,
+			wantErr: "field 4",
+		},
+		{
+			name:    "time as string",
+			body:    `[["1700000000000","100.5","101","99.5","100.25","12.5",1700000899999,"1253.1",42,"6.25","626.5"]]`,
+			wantErr: "field 0",
+		},
+		{
+			name:    "fractional trade count",
+			body:    `[[1700000000000,"100.5","101","99.5","100.25","12.5",1700000899999,"1253.1",4.5,"6.25","626.5"]]`,
+			wantErr: "field 8",
+		},
+		{
+			name:    "close before open",
+			body:    `[[1700000000000,"100.5","101","99.5","100.25","12.5",1699999999999,"1253.1",42,"6.25","626.5"]]`,
+			wantErr: "close time 1699999999999 before open time 1700000000000",
+		},
+		{
+			name:    "error names the row",
+			body:    "[" + valid + `,[1700000900000,"100.5"]]`,
+			wantErr: "invalid kline 1 of BTCUSDT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeKlines([]byte(tt.body), "BTCUSDT")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeKlines: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d klines, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("kline %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
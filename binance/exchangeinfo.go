@@ -0,0 +1,104 @@
+package binance
+
+import (
+	"binance-monitor/models"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// exchangeInfoTTL is how long a fetched exchangeInfo is reused.
+const exchangeInfoTTL = time.Hour
+
+// SymbolInfo is the subset of a /fapi/v1/exchangeInfo symbol entry the
+// monitor uses.
+type SymbolInfo struct {
+	Symbol       string `json:"symbol"`
+	Pair         string `json:"pair"`
+	ContractType string `json:"contractType"`
+	DeliveryDate int64  `json:"deliveryDate"`
+	Status       string `json:"status"`
+	QuoteAsset   string `json:"quoteAsset"`
+}
+
+type exchangeInfo struct {
+	Symbols []SymbolInfo `json:"symbols"`
+}
+
+// ExchangeInfo returns the symbols listed on the exchange. The response is
+// cached for an hour since it is large and changes rarely. Concurrent calls
+// on a cold cache wait for a single request.
+func (c *Client) ExchangeInfo() ([]SymbolInfo, error) {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+
+	c.mu.Lock()
+	if c.symbols != nil && time.Since(c.symbolsAt) < exchangeInfoTTL {
+		symbols := c.symbols
+		c.mu.Unlock()
+		return symbols, nil
+	}
+	c.mu.Unlock()
+
+	body, err := c.get("/fapi/v1/exchangeInfo", url.Values{}, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	var info exchangeInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w", err)
+	}
+
+	c.mu.Lock()
+	c.symbols, c.symbolsAt = info.Symbols, time.Now()
+	c.mu.Unlock()
+	return info.Symbols, nil
+}
+
+// IndexPriceKlines fetches the most recent spot index price klines for the
+// pair of a perpetual symbol.
+func (c *Client) IndexPriceKlines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	body, err := c.get("/fapi/v1/indexPriceKlines", url.Values{
+		"pair":     {symbol},
+		"interval": {interval},
+		"limit":    {strconv.Itoa(limit)},
+	}, klinesWeight(limit))
+	if err != nil {
+		return nil, err
+	}
+	return decodeKlines(body, symbol)
+}
+
+// DeliveryContracts fetches the klines of the quarterly delivery contracts
+// trading on the same pair as a perpetual symbol. Pairs without delivery
+// contracts return an empty slice.
+func (c *Client) DeliveryContracts(symbol, interval string, limit int) ([]models.DeliveryContract, error) {
+	symbols, err := c.ExchangeInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var contracts []models.DeliveryContract
+	for _, s := range symbols {
+		if s.Pair != symbol || s.Status != "TRADING" {
+			continue
+		}
+		if s.ContractType != "CURRENT_QUARTER" && s.ContractType != "NEXT_QUARTER" {
+			continue
+		}
+		klines, err := c.Klines(s.Symbol, interval, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get klines of %s: %w", s.Symbol, err)
+		}
+		contracts = append(contracts, models.DeliveryContract{
+			Symbol:       s.Symbol,
+			ContractType: s.ContractType,
+			DeliveryTime: s.DeliveryDate,
+			Klines:       klines,
+		})
+	}
+	return contracts, nil
+}
@@ -0,0 +1,203 @@
+package binance
+
+import (
+	"binance-monitor/rest"
+	"binance-monitor/strategy"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// WeightLimit1m is the USDⓈ-M request weight limit per IP and minute.
+	WeightLimit1m = 2400
+
+	// statsWeight is charged for the /futures/data statistics endpoints. They
+	// are limited per IP rather than by weight, so count them conservatively.
+	statsWeight = 1
+
+	// spotKlinesWeight is the request weight of /api/v3/klines for any limit.
+	spotKlinesWeight = 2
+)
+
+// ErrWeightBudgetExceeded is returned when a request would exceed the weight
+// budget set with SetWeightBudget.
+var ErrWeightBudgetExceeded = errors.New("binance: request weight budget exceeded")
+
+// APIError is a non-2xx response from the API. Code and Msg are taken from the
+// {"code":...,"msg":...} error body when one is present.
+type APIError struct {
+	StatusCode int
+	Code       int
+	Msg        string
+}
+
+func (e *APIError) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("binance API error: status %d, code %d: %s", e.StatusCode, e.Code, e.Msg)
+	}
+	return fmt.Sprintf("binance API error: status %d", e.StatusCode)
+}
+
+// IsRateLimited reports whether the request was rejected by rate limiting
+// (429) or because the IP is banned (418).
+func (e *APIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusTeapot
+}
+
+// retryable reports whether a response status is worth retrying.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status == http.StatusTeapot || status >= 500
+}
+
+// SetWeightBudget caps the total request weight this client may spend and
+// resets the spent counter. A budget of 0 disables the cap.
+func (c *Client) SetWeightBudget(weight int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = weight
+	c.spent = 0
+}
+
+// WeightSpent returns the weight spent since the last SetWeightBudget.
+func (c *Client) WeightSpent() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.spent
+}
+
+// RemainingWeight returns the weight left in the budget, or -1 without a
+// budget. It implements strategy.WeightBudget.
+func (c *Client) RemainingWeight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.budget == 0 {
+		return -1
+	}
+	return c.budget - c.spent
+}
+
+// UsedWeight1m returns the last X-MBX-USED-WEIGHT-1m value reported by the API.
+func (c *Client) UsedWeight1m() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usedWeight1m
+}
+
+// FetchWeight implements strategy.WeightBudget. The required series are the
+// klines, open interest and long/short ratio; the optional ones are the taker
+// and top trader ratios, depth, index and delivery klines, spot klines,
+// funding rates and the premium index. Delivery klines assume both quarterly
+// contracts are listed, and the cached exchangeInfo is not counted.
+func (c *Client) FetchWeight(limit int) (required, optional int) {
+	required = klinesWeight(limit) + 2*statsWeight
+	optional = 3*statsWeight + // taker and top trader ratios
+		depthWeight(strategy.DepthLimit) +
+		3*klinesWeight(limit) + // index klines and two delivery contracts
+		spotKlinesWeight +
+		2 // funding rates and premium index
+	return required, optional
+}
+
+// klinesWeight returns the request weight of /fapi/v1/klines for a limit.
+func klinesWeight(limit int) int {
+	switch {
+	case limit < 100:
+		return 1
+	case limit < 500:
+		return 2
+	case limit <= 1000:
+		return 5
+	default:
+		return 10
+	}
+}
+
+// depthWeight returns the request weight of /fapi/v1/depth for a limit.
+func depthWeight(limit int) int {
+	switch {
+	case limit <= 50:
+		return 2
+	case limit <= 100:
+		return 5
+	case limit <= 500:
+		return 10
+	default:
+		return 20
+	}
+}
+
+// get performs a GET request against the futures API and returns the raw body.
+// Rate limited and server errors are retried with rest.DefaultPolicy; other
+// errors are returned as *APIError.
+func (c *Client) get(path string, params url.Values, weight int) ([]byte, error) {
+	return c.getFrom(c.baseURL, path, params, weight)
+}
+
+// getFrom is get against an arbitrary base URL. Only futures responses update
+// the per-minute usage, since the spot API keeps a separate weight counter;
+// spot requests are still charged against the budget, once per attempt.
+func (c *Client) getFrom(baseURL, path string, params url.Values, weight int) ([]byte, error) {
+	reqURL := baseURL + path + "?" + params.Encode()
+	return rest.DefaultPolicy.Get(c.httpClient, reqURL, func() error {
+		return c.reserve(weight)
+	}, func(resp *rest.Response) (bool, error) {
+		if baseURL == c.baseURL {
+			c.observe(resp.Header)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return false, nil
+		}
+
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var payload struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if json.Unmarshal(resp.Body, &payload) == nil {
+			apiErr.Code, apiErr.Msg = payload.Code, payload.Msg
+		}
+		return retryable(resp.StatusCode), apiErr
+	})
+}
+
+// reserve charges weight against the budget and, if the API reported the
+// per-minute limit as nearly used up, waits for the next minute window.
+func (c *Client) reserve(weight int) error {
+	c.mu.Lock()
+	if c.budget > 0 && c.spent+weight > c.budget {
+		c.mu.Unlock()
+		return ErrWeightBudgetExceeded
+	}
+	c.spent += weight
+
+	var wait time.Duration
+	if c.usedWeight1m+weight > WeightLimit1m {
+		wait = c.usedWeightAt.Truncate(time.Minute).Add(time.Minute).Sub(time.Now())
+	}
+	c.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// observe records the weight usage reported in the response headers.
+func (c *Client) observe(h http.Header) {
+	used, err := strconv.Atoi(h.Get("X-MBX-USED-WEIGHT-1m"))
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Truncate(time.Minute).After(c.usedWeightAt.Truncate(time.Minute)) || used > c.usedWeight1m {
+		c.usedWeight1m = used
+		c.usedWeightAt = now
+	}
+}
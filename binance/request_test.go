@@ -0,0 +1,177 @@
+package binance
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// scripted answers each request with the next status in its script and
+// 200 once the script is used up.
+type scripted struct {
+	mu      sync.Mutex
+	script  []int
+	header  http.Header
+	request int
+}
+
+func (s *scripted) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status := http.StatusOK
+	if s.request < len(s.script) {
+		status = s.script[s.request]
+	}
+	s.request++
+	s.mu.Unlock()
+
+	for k, v := range s.header {
+		w.Header()[k] = v
+	}
+	if status != http.StatusOK {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"code":-1,"msg":"status %d"}`, status)
+		return
+	}
+	fmt.Fprint(w, `[]`)
+}
+
+func (s *scripted) requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.request
+}
+
+func newScriptedClient(t *testing.T, s *scripted) *Client {
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	c := NewClient(srv.Client(), srv.URL)
+	c.SetSpotBaseURL(srv.URL)
+	return c
+}
+
+func TestGetRetriesServerErrors(t *testing.T) {
+	s := &scripted{script: []int{http.StatusServiceUnavailable}}
+	c := newScriptedClient(t, s)
+
+	if _, err := c.Klines("BTCUSDT", "15m", 10); err != nil {
+		t.Fatalf("Klines: %v", err)
+	}
+	if got := s.requests(); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+	// Every attempt is charged against the budget.
+	if got := c.WeightSpent(); got != 2 {
+		t.Errorf("WeightSpent = %d, want 2", got)
+	}
+}
+
+func TestGetDoesNotRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		retryAfter  string
+		rateLimited bool
+	}{
+		{"client error", http.StatusBadRequest, "", false},
+		{"retry after too long", http.StatusTooManyRequests, "120", true},
+		{"ban too long", http.StatusTeapot, "3600", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &scripted{script: []int{tt.status}, header: http.Header{}}
+			if tt.retryAfter != "" {
+				s.header.Set("Retry-After", tt.retryAfter)
+			}
+			c := newScriptedClient(t, s)
+
+			_, err := c.Klines("BTCUSDT", "15m", 10)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Code != -1 || apiErr.Msg == "" {
+				t.Errorf("got %+v, want status %d with the decoded error body", apiErr, tt.status)
+			}
+			if apiErr.IsRateLimited() != tt.rateLimited {
+				t.Errorf("IsRateLimited = %v, want %v", apiErr.IsRateLimited(), tt.rateLimited)
+			}
+			if got := s.requests(); got != 1 {
+				t.Errorf("got %d requests, want 1", got)
+			}
+		})
+	}
+}
+
+func TestWeightBudget(t *testing.T) {
+	s := &scripted{}
+	c := newScriptedClient(t, s)
+	c.SetWeightBudget(3)
+
+	if _, err := c.Klines("BTCUSDT", "15m", 100); err != nil { // weight 2
+		t.Fatalf("Klines: %v", err)
+	}
+	if _, err := c.Klines("BTCUSDT", "15m", 100); !errors.Is(err, ErrWeightBudgetExceeded) {
+		t.Fatalf("err = %v, want ErrWeightBudgetExceeded", err)
+	}
+	if got := s.requests(); got != 1 {
+		t.Errorf("got %d requests, want the over-budget one not sent", got)
+	}
+	if got := c.RemainingWeight(); got != 1 {
+		t.Errorf("RemainingWeight = %d, want 1", got)
+	}
+
+	c.SetWeightBudget(0)
+	if got := c.RemainingWeight(); got != -1 {
+		t.Errorf("RemainingWeight without a budget = %d, want -1", got)
+	}
+}
+
+func TestObserveUsedWeight(t *testing.T) {
+	s := &scripted{header: http.Header{"X-Mbx-Used-Weight-1m": {"42"}}}
+	c := newScriptedClient(t, s)
+
+	// The spot API keeps its own counter and must not update the futures one.
+	spot := httptest.NewServer(s)
+	defer spot.Close()
+	c.SetSpotBaseURL(spot.URL)
+	if _, err := c.SpotKlines("BTCUSDT", "15m", 10); err != nil {
+		t.Fatalf("SpotKlines: %v", err)
+	}
+	if got := c.UsedWeight1m(); got != 0 {
+		t.Errorf("UsedWeight1m after a spot request = %d, want 0", got)
+	}
+	if _, err := c.Klines("BTCUSDT", "15m", 10); err != nil {
+		t.Fatalf("Klines: %v", err)
+	}
+	if got := c.UsedWeight1m(); got != 42 {
+		t.Errorf("UsedWeight1m = %d, want 42", got)
+	}
+}
+
+func TestRequestWeights(t *testing.T) {
+	tests := []struct {
+		limit  int
+		klines int
+		depth  int
+	}{
+		{5, 1, 2},
+		{50, 1, 2},
+		{99, 1, 5},
+		{100, 2, 5},
+		{499, 2, 10},
+		{500, 5, 10},
+		{1000, 5, 20},
+		{1500, 10, 20},
+	}
+	for _, tt := range tests {
+		if got := klinesWeight(tt.limit); got != tt.klines {
+			t.Errorf("klinesWeight(%d) = %d, want %d", tt.limit, got, tt.klines)
+		}
+		if got := depthWeight(tt.limit); got != tt.depth {
+			t.Errorf("depthWeight(%d) = %d, want %d", tt.limit, got, tt.depth)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tickers24hWeight is the request weight of /fapi/v1/ticker/24hr without a symbol.
+const tickers24hWeight = 40
+
+// Ticker24h is the subset of a /fapi/v1/ticker/24hr entry the monitor uses.
+type Ticker24h struct {
+	Symbol      string `json:"symbol"`
+	QuoteVolume string `json:"quoteVolume"`
+}
+
+// Tickers24h fetches the rolling 24h statistics of all symbols.
+func (c *Client) Tickers24h() ([]Ticker24h, error) {
+	body, err := c.get("/fapi/v1/ticker/24hr", url.Values{}, tickers24hWeight)
+	if err != nil {
+		return nil, err
+	}
+
+	var tickers []Ticker24h
+	if err := json.Unmarshal(body, &tickers); err != nil {
+		return nil, fmt.Errorf("json unmarshal error: %w", err)
+	}
+	return tickers, nil
+}
+
+// UniverseOptions selects the symbols monitored in universe mode.
+type UniverseOptions struct {
+	// TopN is the number of perpetuals selected by 24h quote volume.
+	TopN int
+	// QuoteAsset restricts the selection to one settlement asset; empty means USDT.
+	QuoteAsset string
+	// Include is always monitored in addition to the top N, as long as the
+	// symbol is a trading perpetual.
+	Include []string
+	// Exclude is never monitored, even when ranked in the top N.
+	Exclude []string
+}
+
+// Universe returns the top N TRADING perpetuals by 24h quote volume plus the
+// included symbols, ordered by quote volume. Delisted, settling or paused
+// contracts are not TRADING and drop out on their own, including ones listed
+// in Include.
+func (c *Client) Universe(opts UniverseOptions) ([]string, error) {
+	symbols, err := c.ExchangeInfo()
+	if err != nil {
+		return nil, err
+	}
+	tickers, err := c.Tickers24h()
+	if err != nil {
+		return nil, err
+	}
+
+	quoteAsset := opts.QuoteAsset
+	if quoteAsset == "" {
+		quoteAsset = "USDT"
+	}
+	excluded := toSet(opts.Exclude)
+	included := toSet(opts.Include)
+
+	tradable := map[string]bool{}
+	for _, s := range symbols {
+		if s.ContractType == "PERPETUAL" && s.Status == "TRADING" && !excluded[s.Symbol] {
+			tradable[s.Symbol] = s.QuoteAsset == quoteAsset || included[s.Symbol]
+		}
+	}
+
+	type ranked struct {
+		symbol      string
+		quoteVolume float64
+	}
+	var candidates []ranked
+	for _, t := range tickers {
+		if !tradable[t.Symbol] {
+			continue
+		}
+		quoteVolume, err := strconv.ParseFloat(t.QuoteVolume, 64)
+		if err != nil {
+			continue
+		}
+		candidates = append(candidates, ranked{t.Symbol, quoteVolume})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quoteVolume > candidates[j].quoteVolume })
+
+	var universe []string
+	for i, cand := range candidates {
+		if i < opts.TopN || included[cand.symbol] {
+			universe = append(universe, cand.symbol)
+		}
+	}
+	return universe, nil
+}
+
+// toSet upper-cases and trims symbols into a set, ignoring empty entries.
+func toSet(symbols []string) map[string]bool {
+	set := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		if s = strings.ToUpper(strings.TrimSpace(s)); s != "" {
+			set[s] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,338 @@
+// Package bybit implements strategy.MarketDataSource on top of the Bybit v5
+// REST API for linear (USDT-margined) perpetuals.
+//
+// Bybit linear symbols share the Binance naming (BTCUSDT), so only intervals
+// need to be mapped.
+package bybit
+
+import (
+	"binance-monitor/models"
+	"binance-monitor/rest"
+	"binance-monitor/strategy"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultBaseURL is the production Bybit endpoint.
+const DefaultBaseURL = "https://api.bybit.com"
+
+// Exchange is the venue name reported by the Client.
+const Exchange = "bybit"
+
+const (
+	// Maximum page sizes of the paginated endpoints.
+	klinePageSize        = 1000
+	openInterestPageSize = 200
+	accountRatioPageSize = 500
+)
+
+var _ strategy.MarketDataSource = (*Client)(nil)
+
+// Client fetches market data from the Bybit API.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a new Client. A nil httpClient falls back to
+// http.DefaultClient and an empty baseURL to DefaultBaseURL.
+func NewClient(httpClient *http.Client, baseURL string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// APIError is a Bybit error response (non-zero retCode or non-2xx status).
+type APIError struct {
+	StatusCode int
+	RetCode    int
+	RetMsg     string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("bybit API error: status %d, retCode %d: %s", e.StatusCode, e.RetCode, e.RetMsg)
+}
+
+// rateLimitRetCode is returned with HTTP 200 when the request rate is too high.
+const rateLimitRetCode = 10006
+
+// klineIntervals maps Binance intervals to the fixed set of Bybit kline
+// intervals: minutes as plain numbers ("15", "240") and D/W/M for daily,
+// weekly and monthly bars.
+var klineIntervals = map[string]string{
+	"1m": "1", "3m": "3", "5m": "5", "15m": "15", "30m": "30",
+	"1h": "60", "2h": "120", "4h": "240", "6h": "360", "12h": "720",
+	"1d": "D", "1w": "W", "1M": "M",
+}
+
+// klineInterval maps a Binance interval to the Bybit kline interval naming.
+func klineInterval(interval string) (string, error) {
+	if i, ok := klineIntervals[interval]; ok {
+		return i, nil
+	}
+	return "", fmt.Errorf("unsupported kline interval %q", interval)
+}
+
+// statsInterval maps a Binance period to the Bybit statistics naming used by
+// open interest and account ratio ("5min", "15min", "30min", "1h", "4h", "1d").
+func statsInterval(period string) (string, error) {
+	switch period {
+	case "5m", "15m", "30m":
+		return period + "in", nil
+	case "1h", "4h", "1d":
+		return period, nil
+	}
+	return "", fmt.Errorf("unsupported statistics period %q", period)
+}
+
+// Exchange implements strategy.MarketDataSource.
+func (c *Client) Exchange() string {
+	return Exchange
+}
+
+// Klines fetches the most recent klines of a linear perpetual.
+func (c *Client) Klines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	bybitInterval, err := klineInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	period, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	// The kline endpoint pages by time rather than cursor: each further page
+	// ends just before the oldest kline received so far.
+	var rows [][]string
+	params := url.Values{
+		"category": {"linear"},
+		"symbol":   {symbol},
+		"interval": {bybitInterval},
+	}
+	for len(rows) < limit {
+		pageSize := limit - len(rows)
+		if pageSize > klinePageSize {
+			pageSize = klinePageSize
+		}
+		params.Set("limit", strconv.Itoa(pageSize))
+
+		var result struct {
+			List [][]string `json:"list"`
+		}
+		if err := c.get("/v5/market/kline", params, &result); err != nil {
+			return nil, err
+		}
+		rows = append(rows, result.List...)
+		if len(result.List) < pageSize {
+			break
+		}
+		oldest, err := strconv.ParseInt(result.List[len(result.List)-1][0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kline start time: %w", err)
+		}
+		params.Set("end", strconv.FormatInt(oldest-1, 10))
+	}
+
+	// Rows are [startTime, open, high, low, close, volume, turnover], newest first.
+	klines := make([]models.KlineData, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		row := rows[i]
+		if len(row) < 7 {
+			return nil, fmt.Errorf("invalid kline: expected at least 7 fields, got %d", len(row))
+		}
+		var f [7]float64
+		for j := range f {
+			if f[j], err = strconv.ParseFloat(row[j], 64); err != nil {
+				return nil, fmt.Errorf("invalid kline field %d: %w", j, err)
+			}
+		}
+		klines = append(klines, models.KlineData{
+			Symbol:      symbol,
+			Timestamp:   int64(f[0]),
+			CloseTime:   int64(f[0]) + period.Milliseconds() - 1,
+			Open:        f[1],
+			High:        f[2],
+			Low:         f[3],
+			Close:       f[4],
+			Volume:      f[5],
+			QuoteVolume: f[6],
+		})
+	}
+	return klines, nil
+}
+
+// OpenInterest fetches the open interest history of a linear perpetual.
+// SumOpenInterest is expressed in the base currency, as on Binance.
+func (c *Client) OpenInterest(symbol, period string, limit int) ([]models.BinanceOI, error) {
+	intervalTime, err := statsInterval(period)
+	if err != nil {
+		return nil, err
+	}
+
+	type item struct {
+		OpenInterest string `json:"openInterest"`
+		Timestamp    string `json:"timestamp"`
+	}
+	var items []item
+	err = c.paginate("/v5/market/open-interest", url.Values{
+		"category":     {"linear"},
+		"symbol":       {symbol},
+		"intervalTime": {intervalTime},
+	}, limit, openInterestPageSize, func(list json.RawMessage) (int, error) {
+		var page []item
+		if err := json.Unmarshal(list, &page); err != nil {
+			return 0, err
+		}
+		items = append(items, page...)
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Items are newest first.
+	ois := make([]models.BinanceOI, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		ts, err := strconv.ParseInt(items[i].Timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid open interest timestamp: %w", err)
+		}
+		ois = append(ois, models.BinanceOI{
+			Symbol:          symbol,
+			SumOpenInterest: items[i].OpenInterest,
+			Timestamp:       ts,
+		})
+	}
+	return ois, nil
+}
+
+// LongShortRatio fetches the long/short account ratio history of a linear perpetual.
+func (c *Client) LongShortRatio(symbol, period string, limit int) ([]models.GlobalLongShortRatio, error) {
+	bybitPeriod, err := statsInterval(period)
+	if err != nil {
+		return nil, err
+	}
+
+	type item struct {
+		BuyRatio  string `json:"buyRatio"`
+		SellRatio string `json:"sellRatio"`
+		Timestamp string `json:"timestamp"`
+	}
+	var items []item
+	err = c.paginate("/v5/market/account-ratio", url.Values{
+		"category": {"linear"},
+		"symbol":   {symbol},
+		"period":   {bybitPeriod},
+	}, limit, accountRatioPageSize, func(list json.RawMessage) (int, error) {
+		var page []item
+		if err := json.Unmarshal(list, &page); err != nil {
+			return 0, err
+		}
+		items = append(items, page...)
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Items are newest first.
+	ratios := make([]models.GlobalLongShortRatio, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		ts, err := strconv.ParseInt(items[i].Timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account ratio timestamp: %w", err)
+		}
+		buy, err1 := strconv.ParseFloat(items[i].BuyRatio, 64)
+		sell, err2 := strconv.ParseFloat(items[i].SellRatio, 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid account ratio at %d", ts)
+		}
+		ratio := 0.0
+		if sell > 0 {
+			ratio = buy / sell
+		}
+		ratios = append(ratios, models.GlobalLongShortRatio{
+			Symbol:         symbol,
+			LongShortRatio: strconv.FormatFloat(ratio, 'f', 4, 64),
+			LongAccount:    items[i].BuyRatio,
+			ShortAccount:   items[i].SellRatio,
+			Timestamp:      ts,
+		})
+	}
+	return ratios, nil
+}
+
+// paginate follows nextPageCursor until limit items have been collected or the
+// last page is reached. appendList decodes one page's result.list, appends it
+// to the caller's items and returns the number of items on the page.
+func (c *Client) paginate(path string, params url.Values, limit, pageSize int, appendList func(json.RawMessage) (int, error)) error {
+	total := 0
+	for total < limit {
+		n := limit - total
+		if n > pageSize {
+			n = pageSize
+		}
+		params.Set("limit", strconv.Itoa(n))
+
+		var result struct {
+			List           json.RawMessage `json:"list"`
+			NextPageCursor string          `json:"nextPageCursor"`
+		}
+		if err := c.get(path, params, &result); err != nil {
+			return err
+		}
+		added, err := appendList(result.List)
+		if err != nil {
+			return fmt.Errorf("json unmarshal error: %w", err)
+		}
+		total += added
+		if added == 0 || result.NextPageCursor == "" {
+			break
+		}
+		params.Set("cursor", result.NextPageCursor)
+	}
+	return nil
+}
+
+// get performs a GET request and decodes the result field of the response
+// into out. Rate limited and server errors are retried with rest.DefaultPolicy.
+func (c *Client) get(path string, params url.Values, out interface{}) error {
+	_, err := rest.DefaultPolicy.Get(c.httpClient, c.baseURL+path+"?"+params.Encode(), nil, func(resp *rest.Response) (bool, error) {
+		var payload struct {
+			RetCode int             `json:"retCode"`
+			RetMsg  string          `json:"retMsg"`
+			Result  json.RawMessage `json:"result"`
+		}
+		jsonErr := json.Unmarshal(resp.Body, &payload)
+		if resp.StatusCode == http.StatusOK && jsonErr == nil && payload.RetCode == 0 {
+			if err := json.Unmarshal(payload.Result, out); err != nil {
+				return false, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(resp.Body))
+			}
+			return false, nil
+		}
+		if jsonErr != nil && resp.StatusCode == http.StatusOK {
+			return false, fmt.Errorf("json unmarshal error: %w, body: %s", jsonErr, string(resp.Body))
+		}
+
+		apiErr := &APIError{StatusCode: resp.StatusCode, RetCode: payload.RetCode, RetMsg: payload.RetMsg}
+		// Bybit answers IP rate limiting with 403 and API rate limiting with
+		// retCode 10006. A 403 without either marker is a real refusal (region
+		// block, bad credentials) and retrying it would only burn time.
+		rateLimited := payload.RetCode == rateLimitRetCode ||
+			(resp.StatusCode == http.StatusForbidden && resp.Header.Get("Retry-After") != "")
+		retry := rateLimited || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, apiErr
+	})
+	return err
+}
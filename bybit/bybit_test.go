@@ -0,0 +1,133 @@
+package bybit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKlinesRetriesRateLimit(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// API rate limiting is reported with HTTP 200 and retCode 10006.
+			fmt.Fprint(w, `{"retCode":10006,"retMsg":"Too many visits!","result":{}}`)
+			return
+		}
+		if got := r.URL.Query().Get("interval"); got != "15" {
+			t.Errorf("interval = %q, want 15", got)
+		}
+		// Newest first, as Bybit returns them.
+		fmt.Fprint(w, `{"retCode":0,"retMsg":"OK","result":{"list":[
+			["900000","2","3","1","2.5","10","25"],
+			["0","1","2","0.5","2","20","40"]]}}`)
+	}))
+	defer srv.Close()
+
+	klines, err := NewClient(srv.Client(), srv.URL).Klines("BTCUSDT", "15m", 2)
+	if err != nil {
+		t.Fatalf("Klines: %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("got %d requests, want the rate limited one retried once", got)
+	}
+	if len(klines) != 2 || klines[0].Timestamp != 0 || klines[1].Timestamp != 900000 {
+		t.Fatalf("klines = %+v, want two oldest first", klines)
+	}
+}
+
+func TestAPIErrorNotRetried(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"retCode":10001,"retMsg":"params error: symbol invalid","result":{}}`)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.Client(), srv.URL).Klines("FOOUSDT", "15m", 2)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetCode != 10001 {
+		t.Fatalf("err = %v, want *APIError with retCode 10001", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestForbiddenWithoutRetryAfterNotRetried(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"retCode":10009,"retMsg":"forbidden","result":{}}`)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.Client(), srv.URL).Klines("BTCUSDT", "15m", 2)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("err = %v, want *APIError with status 403", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestForbiddenWithRetryAfterRetried(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"retCode":0,"retMsg":"OK","result":{"list":[["0","1","2","0.5","2","20","40"]]}}`)
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.Client(), srv.URL).Klines("BTCUSDT", "15m", 1); err != nil {
+		t.Fatalf("Klines: %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("got %d requests, want the rate limited one retried once", got)
+	}
+}
+
+func TestKlineInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		want     string // "" if unsupported
+	}{
+		{"1m", "1"},
+		{"3m", "3"},
+		{"5m", "5"},
+		{"15m", "15"},
+		{"30m", "30"},
+		{"1h", "60"},
+		{"2h", "120"},
+		{"4h", "240"},
+		{"6h", "360"},
+		{"12h", "720"},
+		{"1d", "D"},
+		{"1w", "W"},
+		{"1M", "M"},
+		// Valid Binance intervals Bybit has no kline for.
+		{"8h", ""},
+		{"3d", ""},
+		{"2m", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, err := klineInterval(tt.interval)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("klineInterval(%q) = %q, want an error", tt.interval, got)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("klineInterval(%q) = %q, %v, want %q", tt.interval, got, err, tt.want)
+		}
+	}
+}
@@ -1,3 +1,4 @@
+//go:build js && wasm
 
 // Package cache provides functions to interact with a Cloudflare KV namespace
 // for caching signals to avoid duplicate notifications.
@@ -75,3 +76,31 @@ func SetKey(kv js.Value, key string, ttlSeconds int) error {
 	}
 	return nil
 }
+
+// GetValue returns the value of a key in the KV namespace and whether the key
+// exists.
+func GetValue(kv js.Value, key string) (string, bool, error) {
+	promise := kv.Call("get", key)
+	value, err := await(promise)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get key '%s' from KV: %w", key, err)
+	}
+	if value.IsNull() || value.IsUndefined() {
+		return "", false, nil
+	}
+	return value.String(), true, nil
+}
+
+// SetValue sets a key to value in the KV namespace with a specified TTL in
+// seconds.
+func SetValue(kv js.Value, key, value string, ttlSeconds int) error {
+	options := js.Global().Get("Object").New()
+	options.Set("expirationTtl", ttlSeconds)
+
+	promise := kv.Call("put", key, value, options)
+	_, err := await(promise)
+	if err != nil {
+		return fmt.Errorf("failed to set key '%s' in KV: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Command replay records live Binance responses to a fixture directory, or
+// replays a recorded run through strategy.Analyze and prints the resulting
+// signals as JSON so detector changes can be diffed against past market days.
+//
+// Record the current market:
+//
+//	go run ./cmd/replay -record -symbols BTCUSDT,ETHUSDT
+//
+// Replay a recorded run:
+//
+//	go run ./cmd/replay -at 20231030T141500Z -symbols BTCUSDT,ETHUSDT
+package main
+
+import (
+	"binance-monitor/binance"
+	"binance-monitor/fixture"
+	"binance-monitor/models"
+	"binance-monitor/strategy"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type result struct {
+	Symbol   string          `json:"symbol"`
+	Error    string          `json:"error,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Signals  []models.Signal `json:"signals"`
+}
+
+func main() {
+	dir := flag.String("dir", "fixtures", "fixture directory")
+	at := flag.String("at", "", "run timestamp to replay ("+fixture.TimeLayout+"); defaults to now when recording")
+	symbols := flag.String("symbols", "BTCUSDT,ETHUSDT", "comma-separated symbols")
+	configFile := flag.String("config", "", "JSON detector config file (see strategy.Config)")
+	interval := flag.String("interval", "", "kline interval and statistics period (default from -config, else 15m)")
+	limit := flag.Int("limit", 0, "number of periods to fetch (default from -config, else 96)")
+	record := flag.Bool("record", false, "fetch live data and save it as a new fixture run")
+	baseURL := flag.String("base-url", "", "Binance API base URL used when recording")
+	concurrency := flag.Int("concurrency", 4, "number of symbols fetched in parallel")
+	detectors := flag.String("detectors", "", "detector rules, e.g. \"-orderbook,basis@BTCUSDT\"")
+	flag.Parse()
+
+	if err := strategy.DefaultRegistry.Configure(*detectors); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -detectors: %v\n", err)
+		os.Exit(2)
+	}
+	cfg, err := loadConfig(*configFile, *interval, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	strategy.SetConfig(cfg)
+
+	runAt := time.Now().UTC()
+	opts := strategy.DefaultAlignOptions()
+	if *at != "" {
+		t, err := time.Parse(fixture.TimeLayout, *at)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -at: %v\n", err)
+			os.Exit(2)
+		}
+		runAt = t
+		// Candles still forming at the recorded time stay live on replay.
+		opts.Now = func() time.Time { return runAt }
+	} else if !*record {
+		fmt.Fprintln(os.Stderr, "-at is required when replaying")
+		os.Exit(2)
+	}
+
+	var source strategy.MarketDataSource
+	if *record {
+		recorder := fixture.NewRecorder(*dir, runAt, nil)
+		source = binance.NewClient(&http.Client{Transport: recorder}, *baseURL)
+		fmt.Fprintf(os.Stderr, "recording to %s\n", fixture.RunDir(*dir, runAt))
+	} else {
+		source = fixture.NewSource(*dir, runAt)
+	}
+
+	var targets []strategy.Target
+	for _, symbol := range strings.Split(*symbols, ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			targets = append(targets, strategy.Target{Symbol: symbol, Source: source})
+		}
+	}
+
+	var results []result
+	for _, r := range strategy.AnalyzeSymbols(targets, cfg.Interval, cfg.Lookback, *concurrency, opts) {
+		res := result{Symbol: r.Symbol, Warnings: r.Data.Warnings, Signals: []models.Signal{}}
+		if r.Err != nil {
+			res.Error = r.Err.Error()
+		} else if len(r.Signals) > 0 {
+			res.Signals = r.Signals
+		}
+		results = append(results, res)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode results: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig loads the config file, if any, and applies the -interval and
+// -limit flags on top of it.
+func loadConfig(file, interval string, limit int) (*strategy.Config, error) {
+	cfg := strategy.DefaultConfig()
+	if file != "" {
+		var err error
+		if cfg, err = strategy.LoadConfigFile(file); err != nil {
+			return nil, err
+		}
+	}
+	if interval != "" {
+		cfg.Interval = interval
+	}
+	if limit > 0 {
+		cfg.Lookback = limit
+	}
+	return cfg, cfg.Validate()
+}
@@ -0,0 +1,237 @@
+// Command stream runs the monitor as a long-running process on the Binance
+// WebSocket streams and analyzes every symbol the moment a candle closes.
+//
+// Signals are sent to Lark when LARK_WEBHOOK_URL is set, with AI analysis when
+// API_KEY, OPENAI_COMPATIBLE_ENDPOINT and AI_MODEL_NAME are set, and printed
+// as JSON otherwise:
+//
+//	go run ./cmd/stream -symbols BTCUSDT,ETHUSDT
+//
+// With -store, klines and statistics are kept on disk so restarts only fetch
+// the periods since the last run, and -seed backfills that store first:
+//
+//	go run ./cmd/stream -store data -seed 720h -limit 2880
+package main
+
+import (
+	"binance-monitor/binance"
+	"binance-monitor/gemini"
+	"binance-monitor/lark"
+	"binance-monitor/models"
+	"binance-monitor/store"
+	"binance-monitor/strategy"
+	"binance-monitor/stream"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+)
+
+// cooldowns suppress repeated signals of the same type and direction per
+// severity, like the KV cache of the worker.
+var cooldowns = map[models.Severity]time.Duration{
+	models.SeverityInfo:     4 * time.Hour,
+	models.SeverityWarn:     time.Hour,
+	models.SeverityCritical: 30 * time.Minute,
+}
+
+func main() {
+	symbols := flag.String("symbols", "BTCUSDT,ETHUSDT", "comma-separated symbols")
+	configFile := flag.String("config", "", "JSON detector config file (see strategy.Config)")
+	interval := flag.String("interval", "", "kline interval and statistics period (default from -config, else 15m)")
+	limit := flag.Int("limit", 0, "number of periods kept in the rolling window (default from -config, else 96)")
+	streamURL := flag.String("stream-url", stream.DefaultURL, "combined stream endpoint")
+	baseURL := flag.String("base-url", "", "Binance API base URL for REST requests")
+	storeDir := flag.String("store", "", "directory of the local time-series store (disabled if empty)")
+	seed := flag.Duration("seed", 0, "history to backfill into the store before streaming")
+	detectors := flag.String("detectors", "", "detector rules, e.g. \"-orderbook,basis@BTCUSDT\"")
+	minSeverity := flag.String("min-severity", "info", "only send signals of at least this severity (info, warn, critical)")
+	workers := flag.Int("workers", stream.DefaultWorkers, "number of symbols analyzed concurrently")
+	weightBudget := flag.Int("weight-budget", 1200, "max Binance REST request weight per minute (0 disables)")
+	flag.Parse()
+
+	if err := strategy.DefaultRegistry.Configure(*detectors); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -detectors: %v\n", err)
+		os.Exit(2)
+	}
+	cfg, err := loadConfig(*configFile, *interval, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	strategy.SetConfig(cfg)
+
+	var list []string
+	for _, symbol := range strings.Split(*symbols, ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			list = append(list, symbol)
+		}
+	}
+
+	n := &notifier{
+		aiEndpoint: os.Getenv("OPENAI_COMPATIBLE_ENDPOINT"),
+		aiModel:    os.Getenv("AI_MODEL_NAME"),
+		apiKey:     os.Getenv("API_KEY"),
+		sent:       map[string]sentSignal{},
+	}
+	if n.minSeverity, err = models.ParseSeverity(*minSeverity); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -min-severity: %v\n", err)
+		os.Exit(2)
+	}
+	if webhook := os.Getenv("LARK_WEBHOOK_URL"); webhook != "" {
+		n.bot = lark.NewBot(webhook)
+	}
+	if webhook := os.Getenv("LARK_CRITICAL_WEBHOOK_URL"); webhook != "" {
+		n.criticalBot = lark.NewBot(webhook)
+	}
+
+	client := binance.NewClient(nil, *baseURL)
+	var source strategy.MarketDataSource = client
+	if *storeDir != "" {
+		st, err := store.Open(*storeDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cached := store.NewSource(st, client)
+		if *seed > 0 {
+			end := time.Now()
+			for _, symbol := range list {
+				data, err := client.Backfill(symbol, cfg.Interval, end.Add(-*seed), end)
+				if err == nil {
+					err = cached.Seed(data, cfg.Interval)
+				}
+				if err != nil {
+					log.Printf("failed to seed %s: %v", symbol, err)
+					continue
+				}
+				for _, warning := range data.Warnings {
+					log.Printf("%s: %s", symbol, warning)
+				}
+			}
+		}
+		source = cached
+	} else if *seed > 0 {
+		fmt.Fprintln(os.Stderr, "-seed requires -store")
+		os.Exit(2)
+	}
+
+	s, err := stream.New(source, list, cfg.Interval, cfg.Lookback, n.handle)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	s.SetURL(*streamURL)
+	s.SetWorkers(*workers)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Refill the weight budget every minute, in step with the per-minute
+	// limit of the API. When a burst of candle closes drains it, optional
+	// series are skipped first (see strategy.FetchMarketData).
+	client.SetWeightBudget(*weightBudget)
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				client.SetWeightBudget(*weightBudget)
+			}
+		}
+	}()
+
+	if err := s.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal(err)
+	}
+}
+
+// notifier sends the signals of each analysis, skipping signals below
+// minSeverity and signals sent within the cooldown unless they escalate.
+// Critical signals go to criticalBot when it is set.
+type notifier struct {
+	bot, criticalBot            *lark.Bot
+	aiEndpoint, aiModel, apiKey string
+	minSeverity                 models.Severity
+	sent                        map[string]sentSignal
+}
+
+// sentSignal records when a signal was last sent and at which severity.
+type sentSignal struct {
+	at       time.Time
+	severity models.Severity
+}
+
+func (n *notifier) handle(result strategy.SymbolResult) {
+	if result.Err != nil {
+		log.Printf("获取 %s 的市场数据失败: %v", result.Symbol, result.Err)
+		return
+	}
+	for _, warning := range result.Data.Warnings {
+		log.Printf("%s 的部分数据不可用, 相关检测已跳过: %s", result.Symbol, warning)
+	}
+	if len(result.Signals) == 0 {
+		log.Printf("未发现 %s 的交易信号。", result.Symbol)
+		return
+	}
+
+	contextData := strategy.BuildContextData(result.Data)
+	for _, signal := range result.Signals {
+		if signal.Severity.Rank() < n.minSeverity.Rank() {
+			continue
+		}
+		key := fmt.Sprintf("%s:%s:%s:%s", signal.Exchange, signal.Symbol, signal.SignalType, signal.Direction)
+		if prev, ok := n.sent[key]; ok && time.Since(prev.at) < cooldowns[prev.severity] && prev.severity.Rank() >= signal.Severity.Rank() {
+			log.Printf("信号 '%s' 近期已发送过 (%s)，跳过。", key, prev.severity)
+			continue
+		}
+
+		if n.aiEndpoint != "" && n.aiModel != "" && n.apiKey != "" {
+			analysis, err := gemini.GetAIAnalysis(n.aiEndpoint, n.aiModel, n.apiKey, signal, contextData)
+			if err != nil {
+				log.Printf("AI API 分析失败: %v", err)
+			} else {
+				signal.GeminiAnalysis = analysis
+			}
+		}
+
+		bot := n.bot
+		if signal.Severity == models.SeverityCritical && n.criticalBot != nil {
+			bot = n.criticalBot
+		}
+		if bot == nil {
+			out, _ := json.Marshal(signal)
+			fmt.Println(string(out))
+		} else if err := bot.SendSignal(signal); err != nil {
+			log.Printf("发送飞书消息失败: %v", err)
+			continue
+		}
+		n.sent[key] = sentSignal{at: time.Now(), severity: signal.Severity}
+	}
+}
+
+// loadConfig loads the config file, if any, and applies the -interval and
+// -limit flags on top of it.
+func loadConfig(file, interval string, limit int) (*strategy.Config, error) {
+	cfg := strategy.DefaultConfig()
+	if file != "" {
+		var err error
+		if cfg, err = strategy.LoadConfigFile(file); err != nil {
+			return nil, err
+		}
+	}
+	if interval != "" {
+		cfg.Interval = interval
+	}
+	if limit > 0 {
+		cfg.Lookback = limit
+	}
+	return cfg, cfg.Validate()
+}
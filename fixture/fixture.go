@@ -0,0 +1,166 @@
+// Package fixture records raw exchange responses to a directory and replays
+// them later, so a run can be reproduced byte-for-byte offline and kept as a
+// regression corpus for the detectors.
+//
+// Fixtures are laid out as <dir>/<run timestamp>/<symbol>/<interval>/<endpoint>[_<param>-<value>...].json,
+// e.g. fixtures/20231030T141500Z/BTCUSDT/15m/klines_limit-97.json. The suffix
+// holds the query parameters that change the response (limit, startTime,
+// endTime), so backfill pages and calls with different limits each get their
+// own file. Endpoints without an interval, such as premiumIndex, are stored
+// directly under <symbol>, and endpoints without a symbol directly in the run
+// directory.
+package fixture
+
+import (
+	"binance-monitor/binance"
+	"binance-monitor/strategy"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TimeLayout is the format of the run timestamp directory.
+const TimeLayout = "20060102T150405Z"
+
+// replayBaseURL is a placeholder host; the Replayer never hits the network.
+const replayBaseURL = "http://fixture.invalid"
+
+// Recorder is an http.RoundTripper that saves every successful response body
+// under Dir while passing it through unchanged.
+type Recorder struct {
+	dir       string
+	transport http.RoundTripper
+}
+
+// NewRecorder creates a Recorder writing to the run directory for at.
+// A nil transport falls back to http.DefaultTransport.
+func NewRecorder(dir string, at time.Time, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{dir: RunDir(dir, at), transport: transport}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	file, err := fixturePath(r.dir, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(file, body, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write fixture %s: %w", file, err)
+	}
+	return resp, nil
+}
+
+// Replayer is an http.RoundTripper that serves responses saved by a Recorder.
+type Replayer struct {
+	dir string
+}
+
+// NewReplayer creates a Replayer reading from the run directory for at.
+func NewReplayer(dir string, at time.Time) *Replayer {
+	return &Replayer{dir: RunDir(dir, at)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	file, err := fixturePath(r.dir, req)
+	if err != nil {
+		return nil, err
+	}
+	status := http.StatusOK
+	body, err := os.ReadFile(file)
+	if err != nil {
+		// Answer with a non-retryable API error rather than a transport error,
+		// so the client does not back off and retry a missing fixture.
+		status = http.StatusNotFound
+		body, _ = json.Marshal(map[string]interface{}{
+			"code": -1,
+			"msg":  fmt.Sprintf("fixture not found: %v", err),
+		})
+	}
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// NewSource returns a MarketDataSource that serves the fixtures recorded for
+// the run at, decoding them exactly like a live Binance client would.
+func NewSource(dir string, at time.Time) strategy.MarketDataSource {
+	return binance.NewClient(&http.Client{Transport: NewReplayer(dir, at)}, replayBaseURL)
+}
+
+// RunDir returns the directory holding the fixtures of the run at.
+func RunDir(dir string, at time.Time) string {
+	return filepath.Join(dir, at.UTC().Format(TimeLayout))
+}
+
+// windowParams are the query parameters that select which points a response
+// holds, in the order they appear in a fixture file name.
+var windowParams = []string{"limit", "startTime", "endTime"}
+
+// fixturePath maps a request to its fixture file, keyed by symbol (or pair),
+// interval (or statistics period, if any), endpoint name and the window
+// parameters. Requests without a symbol, such as exchangeInfo, are stored
+// directly in the run directory.
+// Spot endpoints (/api/...) are prefixed with "spot_" so they do not collide
+// with their futures counterparts.
+func fixturePath(runDir string, req *http.Request) (string, error) {
+	q := req.URL.Query()
+	symbol := q.Get("symbol")
+	if symbol == "" {
+		symbol = q.Get("pair")
+	}
+	interval := q.Get("interval")
+	if interval == "" {
+		interval = q.Get("period")
+	}
+	endpoint := path.Base(req.URL.Path)
+	if endpoint == "/" || endpoint == "." {
+		return "", fmt.Errorf("cannot derive fixture key from %s", req.URL)
+	}
+	if strings.HasPrefix(req.URL.Path, "/api/") {
+		endpoint = "spot_" + endpoint
+	}
+	name := endpoint
+	for _, param := range windowParams {
+		if v := q.Get(param); v != "" {
+			name += "_" + param + "-" + v
+		}
+	}
+	if strings.ContainsAny(symbol+interval+name, `/\.`) {
+		return "", fmt.Errorf("invalid fixture key %s/%s/%s", symbol, interval, name)
+	}
+	return filepath.Join(runDir, symbol, interval, name+".json"), nil
+}
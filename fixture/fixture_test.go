@@ -0,0 +1,91 @@
+package fixture
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFixturePath(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/fapi/v1/klines?symbol=BTCUSDT&interval=15m&limit=97", "BTCUSDT/15m/klines_limit-97.json"},
+		{"/fapi/v1/klines?symbol=BTCUSDT&interval=15m&limit=1000&startTime=1000&endTime=2000", "BTCUSDT/15m/klines_limit-1000_startTime-1000_endTime-2000.json"},
+		{"/fapi/v1/klines?symbol=BTCUSDT&interval=15m&limit=1000&startTime=3000&endTime=4000", "BTCUSDT/15m/klines_limit-1000_startTime-3000_endTime-4000.json"},
+		{"/futures/data/openInterestHist?symbol=BTCUSDT&period=15m&limit=96", "BTCUSDT/15m/openInterestHist_limit-96.json"},
+		{"/api/v3/klines?symbol=BTCUSDT&interval=15m&limit=97", "BTCUSDT/15m/spot_klines_limit-97.json"},
+		{"/fapi/v1/indexPriceKlines?pair=BTCUSDT&interval=15m&limit=2", "BTCUSDT/15m/indexPriceKlines_limit-2.json"},
+		{"/fapi/v1/premiumIndex?symbol=BTCUSDT", "BTCUSDT/premiumIndex.json"},
+		{"/fapi/v1/exchangeInfo", "exchangeInfo.json"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+		got, err := fixturePath("run", req)
+		if err != nil {
+			t.Errorf("%s: %v", tt.url, err)
+			continue
+		}
+		if want := filepath.Join("run", filepath.FromSlash(tt.want)); got != want {
+			t.Errorf("%s: got %s, want %s", tt.url, got, want)
+		}
+	}
+}
+
+func TestFixturePathRejectsTraversal(t *testing.T) {
+	for _, url := range []string{
+		"/fapi/v1/klines?symbol=../etc&interval=15m",
+		"/fapi/v1/klines?symbol=BTCUSDT&interval=15m&limit=..%2F1",
+		"/",
+	} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		if got, err := fixturePath("run", req); err == nil {
+			t.Errorf("%s: got %s, want an error", url, got)
+		}
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Query().Get("limit")))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	at := time.Date(2023, 10, 30, 14, 15, 0, 0, time.UTC)
+	record := &http.Client{Transport: NewRecorder(dir, at, nil)}
+	replay := &http.Client{Transport: NewReplayer(dir, at)}
+
+	for _, limit := range []string{"2", "97"} {
+		resp, err := record.Get(srv.URL + "/fapi/v1/klines?symbol=BTCUSDT&interval=15m&limit=" + limit)
+		if err != nil {
+			t.Fatalf("record: %v", err)
+		}
+		resp.Body.Close()
+	}
+	// Each limit replays its own response instead of the last one recorded.
+	for _, limit := range []string{"2", "97"} {
+		resp, err := replay.Get(replayBaseURL + "/fapi/v1/klines?symbol=BTCUSDT&interval=15m&limit=" + limit)
+		if err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != limit {
+			t.Errorf("limit=%s: got status %d body %q", limit, resp.StatusCode, body)
+		}
+	}
+
+	resp, err := replay.Get(replayBaseURL + "/fapi/v1/klines?symbol=BTCUSDT&interval=15m&limit=500")
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unrecorded limit: got status %d, want 404", resp.StatusCode)
+	}
+}
@@ -1,4 +1,3 @@
-
 // Package gemini provides a client for communicating with an OpenAI-compatible API.
 package gemini
 
@@ -37,7 +36,8 @@ type OpenAIResponse struct {
 	Choices []Choice `json:"choices"`
 }
 
-// Choice contains the generated message.	ype Choice struct {
+// Choice contains the generated message.
+type Choice struct {
 	Message Message `json:"message"`
 }
 
@@ -49,7 +49,7 @@ func GetAIAnalysis(endpoint, modelName, apiKey string, signal models.Signal, con
 
 	// 1. Construct the prompt using the messages format
 	systemPrompt := `You are a professional crypto market analyst. Your task is to provide a concise and insightful analysis in Chinese based on the data provided. Your entire response must follow this three-section format strictly: "【核心信号】", "【市场背景】", and "【潜在影响】". Be concise and straight to the point.`
-	userPrompt := fmt.Sprintf("A trading signal was detected for %s.\n\n**Detected Signal:**\n- Signal Type: %s\n- Description: %s\n\n**Market Context Data:**\n%s\n\nNow, please provide your analysis based on the instructions.", signal.Symbol, signal.SignalType, signal.Description, contextData)
+	userPrompt := fmt.Sprintf("A trading signal was detected for %s on %s.\n\n**Detected Signal:**\n- Signal Type: %s\n- Direction: %s\n- Severity: %s\n- Score: %.2f (multiple of the trigger threshold)\n- Description: %s\n\n**Market Context Data:**\n%s\n\nNow, please provide your analysis based on the instructions.", signal.Symbol, signal.Exchange, signal.SignalType, signal.Direction, signal.Severity, signal.Score, signal.Description, contextData)
 
 	// 2. Create the request payload
 	reqPayload := OpenAIRequest{
@@ -85,7 +85,7 @@ func GetAIAnalysis(endpoint, modelName, apiKey string, signal models.Signal, con
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("openai-compatible API returned non-200 status: %d, body: %s", resp.StatusCode, string(tbody))
+		return "", fmt.Errorf("openai-compatible API returned non-200 status: %d, body: %s", resp.StatusCode, string(body))
 	}
 
 	// 4. Parse the response
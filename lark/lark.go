@@ -1,4 +1,3 @@
-
 package lark
 
 import (
@@ -50,7 +49,7 @@ type LarkCard struct {
 }
 
 type CardDef struct {
-	Header   HeaderDef    `json:"header"`
+	Header   HeaderDef     `json:"header"`
 	Elements []interface{} `json:"elements"`
 }
 
@@ -84,15 +83,75 @@ type NoteDef struct {
 	Elements []TextDef `json:"elements"`
 }
 
-func formatSignalToLarkCard(signal models.Signal) ([]byte, error) {
-	cardColor := "blue" // Default color
+// symbolTitle returns the symbol with its exchange, e.g. "BTCUSDT (OKX)".
+// Binance signals keep the bare symbol and cross-exchange aggregates are
+// marked as such, e.g. "BTC (全市场)".
+func symbolTitle(signal models.Signal) string {
+	if signal.Exchange == "" || signal.Exchange == "binance" {
+		return signal.Symbol
+	}
+	if signal.Exchange == "aggregate" {
+		return signal.Symbol + " (全市场)"
+	}
+	return fmt.Sprintf("%s (%s)", signal.Symbol, strings.ToUpper(signal.Exchange))
+}
+
+// directionLabels and severityLabels are the Chinese labels shown on cards.
+var (
+	directionLabels = map[models.Direction]string{models.Bullish: "看涨", models.Bearish: "看跌", models.Neutral: "中性"}
+	severityLabels  = map[models.Severity]string{models.SeverityInfo: "提示", models.SeverityWarn: "警告", models.SeverityCritical: "严重"}
+)
+
+// cardColor picks the header color of the signal type, so each type keeps
+// its own color whatever its severity; the severity is shown in the title
+// (see severityTag). Red is reserved for liquidations.
+func cardColor(signal models.Signal) string {
 	switch signal.SignalType {
 	case models.VolumeSignal, models.OpenInterestSignal:
-		cardColor = "orange"
+		return "orange"
 	case models.LSRatioSignal:
-		cardColor = "purple"
+		return "purple"
+	case models.TakerImbalanceSignal:
+		return "turquoise"
+	case models.SmartMoneyDivergenceSignal:
+		return "indigo"
+	case models.FundingSignal:
+		return "yellow"
+	case models.LiquidationSignal:
+		return "red"
+	case models.OrderBookSignal:
+		return "wathet"
+	case models.BasisSignal:
+		return "carmine"
+	case models.SpotPerpVolumeSignal:
+		return "violet"
+	case models.CompositeSignal:
+		return "green"
+	}
+	return "blue"
+}
+
+// severityTag returns the title prefix of warn and critical signals.
+func severityTag(severity models.Severity) string {
+	switch severity {
+	case models.SeverityCritical, models.SeverityWarn:
+		return "【" + severityLabels[severity] + "】"
 	}
+	return ""
+}
 
+// titleIcon returns the header icon of the signal's direction.
+func titleIcon(direction models.Direction) string {
+	switch direction {
+	case models.Bearish:
+		return "📉"
+	case models.Neutral:
+		return "📊"
+	}
+	return "📈"
+}
+
+func formatSignalToLarkCard(signal models.Signal) ([]byte, error) {
 	elements := []interface{}{
 		DivDef{
 			Tag:  "div",
@@ -101,6 +160,36 @@ func formatSignalToLarkCard(signal models.Signal) ([]byte, error) {
 		HrDef{Tag: "hr"},
 	}
 
+	if signal.Severity != "" {
+		elements = append(elements, DivDef{Tag: "div", Fields: []FieldDef{
+			{IsShort: true, Text: TextDef{Tag: "lark_md", Content: "**方向**\n" + directionLabels[signal.Direction]}},
+			{IsShort: true, Text: TextDef{Tag: "lark_md", Content: "**级别**\n" + severityLabels[signal.Severity]}},
+			{IsShort: true, Text: TextDef{Tag: "lark_md", Content: fmt.Sprintf("**强度**\n%.2f", signal.Score)}},
+		}}, HrDef{Tag: "hr"})
+	}
+
+	if components, ok := signal.Meta["components"].([]models.CompositeComponent); ok && len(components) > 0 {
+		lines := make([]string, len(components))
+		for i, c := range components {
+			lines[i] = fmt.Sprintf("- **%s** (%s, 权重 %.1f): %s", c.SignalType, directionLabels[c.Direction], c.Weight, c.Description)
+		}
+		elements = append(elements, DivDef{
+			Tag:  "div",
+			Text: &TextDef{Tag: "lark_md", Content: "**组成信号**\n" + strings.Join(lines, "\n")},
+		}, HrDef{Tag: "hr"})
+	}
+
+	if predicted, ok := signal.Meta["predicted_funding_rate"].(float64); ok {
+		fields := []FieldDef{
+			{IsShort: true, Text: TextDef{Tag: "lark_md", Content: fmt.Sprintf("**预测资金费率**\n%.4f%%", predicted*100)}},
+		}
+		if next, ok := signal.Meta["next_funding_time"].(int64); ok && next > 0 {
+			nextTime := time.Unix(0, next*int64(time.Millisecond)).In(time.FixedZone("CST", 8*60*60))
+			fields = append(fields, FieldDef{IsShort: true, Text: TextDef{Tag: "lark_md", Content: "**下次结算**\n" + nextTime.Format("15:04 CST")}})
+		}
+		elements = append(elements, DivDef{Tag: "div", Fields: fields}, HrDef{Tag: "hr"})
+	}
+
 	if signal.GeminiAnalysis != "" {
 		// Sanitize Gemini analysis for Lark Markdown
 		formattedAnalysis := strings.ReplaceAll(signal.GeminiAnalysis, "【", "**【")
@@ -128,9 +217,9 @@ func formatSignalToLarkCard(signal models.Signal) ([]byte, error) {
 			Header: HeaderDef{
 				Title: TextDef{
 					Tag:     "plain_text",
-					Content: fmt.Sprintf("📈 %s 交易信号: %s", signal.Symbol, signal.SignalType),
+					Content: fmt.Sprintf("%s %s%s 交易信号: %s", titleIcon(signal.Direction), severityTag(signal.Severity), symbolTitle(signal), signal.SignalType),
 				},
-				Template: cardColor,
+				Template: cardColor(signal),
 			},
 			Elements: elements,
 		},
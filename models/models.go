@@ -1,18 +1,25 @@
-
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
 
 // --- Binance API Data Structures ---
 
 // BinanceKline 代表从币安API获取的单条K线原始数据
-type BinanceKline [12]interface{}
+// 字段依次为: 开盘时间, 开, 高, 低, 收, 成交量, 收盘时间, 成交额, 成交笔数,
+// 主动买入成交量, 主动买入成交额, 忽略字段。保留原始 JSON 以便严格校验每个字段。
+type BinanceKline []json.RawMessage
 
 // BinanceOI 代表从币安API获取的持仓量数据
 type BinanceOI struct {
-	Symbol          string `json:"symbol"`
-	SumOpenInterest string `json:"sumOpenInterest"`
-	Timestamp       int64  `json:"timestamp"`
+	Symbol               string `json:"symbol"`
+	SumOpenInterest      string `json:"sumOpenInterest"`      // 持仓量 (基础币种)
+	SumOpenInterestValue string `json:"sumOpenInterestValue"` // 持仓价值 (USD), 部分交易所不提供
+	Timestamp            int64  `json:"timestamp"`
 }
 
 // GlobalLongShortRatio 代表从币安API获取的多空账户比数据
@@ -24,6 +31,65 @@ type GlobalLongShortRatio struct {
 	Timestamp      int64  `json:"timestamp"`
 }
 
+// TopLongShortRatio 代表从币安API获取的大户多空比数据 (按账户数或按持仓量)
+// 按持仓量统计时, LongAccount/ShortAccount 为多/空持仓占比
+type TopLongShortRatio struct {
+	Symbol         string `json:"symbol"`
+	LongShortRatio string `json:"longShortRatio"`
+	LongAccount    string `json:"longAccount"`
+	ShortAccount   string `json:"shortAccount"`
+	Timestamp      int64  `json:"timestamp"`
+}
+
+// TakerLongShortRatio 代表从币安API获取的主动买卖量比数据
+type TakerLongShortRatio struct {
+	BuySellRatio string `json:"buySellRatio"`
+	BuyVol       string `json:"buyVol"`
+	SellVol      string `json:"sellVol"`
+	Timestamp    int64  `json:"timestamp"`
+}
+
+// FundingRate 代表从币安API获取的历史资金费率数据
+type FundingRate struct {
+	Symbol      string `json:"symbol"`
+	FundingRate string `json:"fundingRate"`
+	FundingTime int64  `json:"fundingTime"`
+	MarkPrice   string `json:"markPrice"`
+}
+
+// PremiumIndex 代表从币安API获取的标记价格、指数价格与预测资金费率
+type PremiumIndex struct {
+	Symbol               string `json:"symbol"`
+	MarkPrice            string `json:"markPrice"`
+	IndexPrice           string `json:"indexPrice"`
+	EstimatedSettlePrice string `json:"estimatedSettlePrice"`
+	LastFundingRate      string `json:"lastFundingRate"` // 本期预测资金费率
+	InterestRate         string `json:"interestRate"`
+	NextFundingTime      int64  `json:"nextFundingTime"`
+	Time                 int64  `json:"time"`
+}
+
+// ForceOrder 代表从币安获取的一笔强平订单
+// Side 为 SELL 表示多头仓位被强平, BUY 表示空头仓位被强平
+type ForceOrder struct {
+	Symbol       string `json:"symbol"`
+	Price        string `json:"price"`
+	OrigQty      string `json:"origQty"`
+	ExecutedQty  string `json:"executedQty"`
+	AveragePrice string `json:"averagePrice"`
+	Status       string `json:"status"`
+	Side         string `json:"side"`
+	Time         int64  `json:"time"`
+}
+
+// BinanceDepth 代表从币安API获取的订单簿快照原始数据, 每档为 [价格, 数量]
+type BinanceDepth struct {
+	LastUpdateID int64       `json:"lastUpdateId"`
+	EventTime    int64       `json:"E"`
+	TransactTime int64       `json:"T"`
+	Bids         [][2]string `json:"bids"`
+	Asks         [][2]string `json:"asks"`
+}
 
 // --- Internal Data Structures ---
 
@@ -32,29 +98,167 @@ type SignalType string
 
 const (
 	// New Signals based on the new strategy
-	VolumeSignal     SignalType = "成交量异常"
-	OpenInterestSignal SignalType = "持仓量异动"
-	LSRatioSignal    SignalType = "多空比极端"
-	CompositeSignal  SignalType = "复合信号"
+	VolumeSignal               SignalType = "成交量异常"
+	OpenInterestSignal         SignalType = "持仓量异动"
+	LSRatioSignal              SignalType = "多空比极端"
+	TakerImbalanceSignal       SignalType = "主动买卖失衡"
+	SmartMoneyDivergenceSignal SignalType = "大户散户背离"
+	FundingSignal              SignalType = "资金费率异常"
+	LiquidationSignal          SignalType = "强平瀑布"
+	OrderBookSignal            SignalType = "盘口失衡"
+	BasisSignal                SignalType = "基差异常"
+	SpotPerpVolumeSignal       SignalType = "期现成交量比异常"
+	CompositeSignal            SignalType = "复合信号"
 )
 
 // KlineData 代表内部使用的、格式化后的单条K线数据
 type KlineData struct {
 	Symbol    string
-	Timestamp int64
+	Timestamp int64 // 开盘时间 (毫秒)
+	CloseTime int64 // 收盘时间 (毫秒), 即下一根K线开盘时间 - 1
 	Open      float64
 	High      float64
 	Low       float64
 	Close     float64
-	Volume    float64
+	Volume    float64 // 成交量 (基础币种)
+	// QuoteVolume 成交额 (计价币种), 数据源不提供时为 0
+	QuoteVolume float64
+	// TradeCount 成交笔数, 数据源不提供时为 0
+	TradeCount int64
+	// TakerBuyVolume 主动买入成交量 (基础币种)
+	TakerBuyVolume float64
+	// TakerBuyQuoteVolume 主动买入成交额 (计价币种)
+	TakerBuyQuoteVolume float64
+	// Synthetic 表示该K线是对齐时为补齐缺失周期而生成的, 不是真实成交
+	Synthetic bool `json:",omitempty"`
+}
+
+// LiquidationData 代表按K线周期聚合后的强平名义价值
+type LiquidationData struct {
+	Symbol        string
+	Timestamp     int64   // 周期开始时间, 与对应K线的 Timestamp 一致
+	LongNotional  float64 // 多头被强平的名义价值
+	ShortNotional float64 // 空头被强平的名义价值
+	// Unknown 表示该周期的强平订单未被完整采集 (例如早于开始订阅的时间), 名义价值不可用
+	Unknown bool
+}
+
+// OrderBookLevel 代表订单簿中的单个价位
+type OrderBookLevel struct {
+	Price    float64
+	Quantity float64
+}
+
+// OrderBook 代表解析后的订单簿快照, Bids 按价格从高到低, Asks 按价格从低到高
+type OrderBook struct {
+	Symbol    string
+	Timestamp int64
+	Bids      []OrderBookLevel
+	Asks      []OrderBookLevel
+}
+
+// DepthLiquidity 代表中间价上下一定范围内的累计挂单名义价值
+type DepthLiquidity struct {
+	RangePercent float64 // 距中间价的范围, 例如 1 表示 ±1%
+	BidNotional  float64
+	AskNotional  float64
+}
+
+// DeliveryContract 代表某一交割合约及其K线
+type DeliveryContract struct {
+	Symbol       string // 例如 BTCUSDT_240628
+	ContractType string // CURRENT_QUARTER 或 NEXT_QUARTER
+	DeliveryTime int64  // 交割时间 (毫秒)
+	Klines       []KlineData
+}
+
+// BasisPoint 代表某一时刻合约价格相对现货指数的基差
+type BasisPoint struct {
+	Timestamp  int64
+	Price      float64 // 合约收盘价
+	IndexPrice float64 // 现货指数收盘价
+	Basis      float64 // (合约价 - 指数价) / 指数价
+	Annualized float64 // 年化基差
+}
+
+// BasisSeries 代表某一合约的滚动基差序列
+type BasisSeries struct {
+	Symbol       string
+	ContractType string // PERPETUAL, CURRENT_QUARTER 或 NEXT_QUARTER
+	DeliveryTime int64  // 交割时间 (毫秒), 永续合约为 0
+	Points       []BasisPoint
+}
+
+// VolumeRatioPoint 代表某一K线周期内合约与现货的成交额 (计价币种) 及其比值
+type VolumeRatioPoint struct {
+	Timestamp  int64
+	PerpVolume float64
+	SpotVolume float64
+	Ratio      float64 // PerpVolume / SpotVolume
+}
+
+// Direction 定义了信号的多空方向
+type Direction string
+
+const (
+	Bullish Direction = "bullish"
+	Bearish Direction = "bearish"
+	Neutral Direction = "neutral"
+)
+
+// Severity 定义了信号的严重程度, 按 info < warn < critical 排序
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarn     Severity = "warn"
+	SeverityCritical Severity = "critical"
+)
+
+// Rank 返回严重程度的排序值 (info 为 1, critical 为 3), 未知值为 0
+func (s Severity) Rank() int {
+	switch s {
+	case SeverityInfo:
+		return 1
+	case SeverityWarn:
+		return 2
+	case SeverityCritical:
+		return 3
+	}
+	return 0
+}
+
+// ParseSeverity 解析 "info"、"warn" 或 "critical"
+func ParseSeverity(s string) (Severity, error) {
+	severity := Severity(strings.ToLower(strings.TrimSpace(s)))
+	if severity.Rank() == 0 {
+		return "", fmt.Errorf("invalid severity %q (want info, warn or critical)", s)
+	}
+	return severity, nil
 }
 
 // Signal 代表一个分析后得出的、准备发送的信号
 type Signal struct {
-	Symbol         string                 `json:"symbol"`
-	SignalType     SignalType             `json:"signal_type"`
-	Timestamp      time.Time              `json:"timestamp"`
-	Description    string                 `json:"description"` // 简要描述，例如 "成交量 Z-Score > 2.0"
-	Meta           map[string]interface{} `json:"meta"`        // 存储信号相关的元数据，如Z-Score值, 变化率等
+	Symbol      string     `json:"symbol"`
+	Exchange    string     `json:"exchange,omitempty"` // 信号来源交易所, 例如 "binance"
+	SignalType  SignalType `json:"signal_type"`
+	Timestamp   time.Time  `json:"timestamp"`
+	Description string     `json:"description"` // 简要描述，例如 "成交量 Z-Score > 2.0"
+	// Direction 是信号暗示的价格方向, Severity 是严重程度
+	Direction Direction `json:"direction"`
+	Severity  Severity  `json:"severity"`
+	// Score 是信号强度, 即检测指标相对触发阈值的倍数 (刚好触发时约为 1)
+	Score          float64                `json:"score"`
+	Meta           map[string]interface{} `json:"meta"`                      // 存储信号相关的元数据，如Z-Score值, 变化率等
 	GeminiAnalysis string                 `json:"gemini_analysis,omitempty"` // Gemini的分析结果
 }
+
+// CompositeComponent 代表复合信号中的一个组成信号, 保存在复合信号的 Meta["components"] 中
+type CompositeComponent struct {
+	Detector    string     `json:"detector"`
+	SignalType  SignalType `json:"signal_type"`
+	Direction   Direction  `json:"direction"`
+	Severity    Severity   `json:"severity"`
+	Weight      float64    `json:"weight"`
+	Description string     `json:"description"`
+}
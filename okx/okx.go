@@ -0,0 +1,285 @@
+// Package okx implements strategy.MarketDataSource on top of the OKX v5 REST
+// API for USDT-margined perpetual swaps.
+//
+// Symbols use the Binance naming of the rest of the monitor (BTCUSDT) and are
+// mapped to OKX instrument IDs (BTC-USDT-SWAP) internally.
+package okx
+
+import (
+	"binance-monitor/models"
+	"binance-monitor/rest"
+	"binance-monitor/strategy"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultBaseURL is the production OKX endpoint.
+const DefaultBaseURL = "https://www.okx.com"
+
+// Exchange is the venue name reported by the Client.
+const Exchange = "okx"
+
+var _ strategy.MarketDataSource = (*Client)(nil)
+
+// quoteAssets are the settlement currencies recognised when mapping symbols.
+var quoteAssets = []string{"USDT", "USDC"}
+
+// Client fetches market data from the OKX API.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient creates a new Client. A nil httpClient falls back to
+// http.DefaultClient and an empty baseURL to DefaultBaseURL.
+func NewClient(httpClient *http.Client, baseURL string) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// APIError is an OKX error response. OKX reports most errors with HTTP 200 and
+// a non-zero code in the body.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Msg        string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("okx API error: status %d, code %s: %s", e.StatusCode, e.Code, e.Msg)
+}
+
+// InstID maps a symbol such as BTCUSDT to its swap instrument ID BTC-USDT-SWAP.
+func InstID(symbol string) (string, error) {
+	for _, quote := range quoteAssets {
+		if base := strings.TrimSuffix(symbol, quote); base != symbol && base != "" {
+			return base + "-" + quote + "-SWAP", nil
+		}
+	}
+	return "", fmt.Errorf("cannot map symbol %q to an OKX swap", symbol)
+}
+
+// Symbol maps a swap instrument ID such as BTC-USDT-SWAP back to BTCUSDT.
+func Symbol(instID string) (string, error) {
+	parts := strings.Split(instID, "-")
+	if len(parts) != 3 || parts[2] != "SWAP" {
+		return "", fmt.Errorf("not an OKX swap instrument: %q", instID)
+	}
+	return parts[0] + parts[1], nil
+}
+
+// bars maps Binance intervals to OKX candle bars. Bars of 6h and longer use
+// the "utc" variants: the plain ones (6H, 1D, ...) open at UTC+8 and would not
+// line up with the Binance grid.
+var bars = map[string]string{
+	"1m": "1m", "3m": "3m", "5m": "5m", "15m": "15m", "30m": "30m",
+	"1h": "1H", "2h": "2H", "4h": "4H", "6h": "6Hutc", "12h": "12Hutc",
+	"1d": "1Dutc", "2d": "2Dutc", "3d": "3Dutc", "1w": "1Wutc",
+}
+
+// statsPeriods maps Binance periods to the periods of the rubik statistics
+// endpoints, which start at 5m and end at one day.
+var statsPeriods = map[string]string{
+	"5m": "5m", "15m": "15m", "30m": "30m",
+	"1h": "1H", "2h": "2H", "4h": "4H", "6h": "6Hutc", "12h": "12Hutc",
+	"1d": "1Dutc",
+}
+
+// bar maps a Binance interval to the OKX candle bar.
+func bar(interval string) (string, error) {
+	if b, ok := bars[interval]; ok {
+		return b, nil
+	}
+	return "", fmt.Errorf("interval %q is not supported by OKX", interval)
+}
+
+// statsPeriod maps a Binance period to the OKX statistics period.
+func statsPeriod(period string) (string, error) {
+	if p, ok := statsPeriods[period]; ok {
+		return p, nil
+	}
+	return "", fmt.Errorf("statistics period %q is not supported by OKX", period)
+}
+
+// Exchange implements strategy.MarketDataSource.
+func (c *Client) Exchange() string {
+	return Exchange
+}
+
+// Klines fetches the most recent candles of a swap.
+func (c *Client) Klines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	instID, err := InstID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	period, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	okxBar, err := bar(interval)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.get("/api/v5/market/candles", url.Values{
+		"instId": {instID},
+		"bar":    {okxBar},
+		"limit":  {strconv.Itoa(limit)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are [ts, o, h, l, c, vol(contracts), volCcy(base), volCcyQuote, confirm], newest first.
+	klines := make([]models.KlineData, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		f, err := parseRow(rows[i], 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid candle: %w", err)
+		}
+		klines = append(klines, models.KlineData{
+			Symbol:      symbol,
+			Timestamp:   int64(f[0]),
+			CloseTime:   int64(f[0]) + period.Milliseconds() - 1,
+			Open:        f[1],
+			High:        f[2],
+			Low:         f[3],
+			Close:       f[4],
+			Volume:      f[6],
+			QuoteVolume: f[7],
+		})
+	}
+	return klines, nil
+}
+
+// OpenInterest fetches the open interest history of a swap from the rubik
+// statistics endpoint. SumOpenInterest is expressed in the base currency.
+func (c *Client) OpenInterest(symbol, period string, limit int) ([]models.BinanceOI, error) {
+	instID, err := InstID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	okxPeriod, err := statsPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.get("/api/v5/rubik/stat/contracts/open-interest-history", url.Values{
+		"instId": {instID},
+		"period": {okxPeriod},
+		"limit":  {strconv.Itoa(limit)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are [ts, oi(contracts), oiCcy(base), oiUsd], newest first.
+	ois := make([]models.BinanceOI, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		f, err := parseRow(rows[i], 3)
+		if err != nil {
+			return nil, fmt.Errorf("invalid open interest: %w", err)
+		}
+		oi := models.BinanceOI{
+			Symbol:          symbol,
+			SumOpenInterest: rows[i][2],
+			Timestamp:       int64(f[0]),
+		}
+		if len(rows[i]) > 3 {
+			oi.SumOpenInterestValue = rows[i][3]
+		}
+		ois = append(ois, oi)
+	}
+	return ois, nil
+}
+
+// LongShortRatio fetches the long/short account ratio history of a swap.
+func (c *Client) LongShortRatio(symbol, period string, limit int) ([]models.GlobalLongShortRatio, error) {
+	instID, err := InstID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	okxPeriod, err := statsPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.get("/api/v5/rubik/stat/contracts/long-short-account-ratio-contract", url.Values{
+		"instId": {instID},
+		"period": {okxPeriod},
+		"limit":  {strconv.Itoa(limit)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows are [ts, longShortAcctRatio], newest first.
+	ratios := make([]models.GlobalLongShortRatio, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		f, err := parseRow(rows[i], 2)
+		if err != nil {
+			return nil, fmt.Errorf("invalid long/short ratio: %w", err)
+		}
+		ratio := f[1]
+		ratios = append(ratios, models.GlobalLongShortRatio{
+			Symbol:         symbol,
+			LongShortRatio: rows[i][1],
+			LongAccount:    strconv.FormatFloat(ratio/(1+ratio), 'f', 4, 64),
+			ShortAccount:   strconv.FormatFloat(1/(1+ratio), 'f', 4, 64),
+			Timestamp:      int64(f[0]),
+		})
+	}
+	return ratios, nil
+}
+
+// parseRow parses the first n fields of an OKX data row as floats.
+func parseRow(row []string, n int) ([]float64, error) {
+	if len(row) < n {
+		return nil, fmt.Errorf("expected at least %d fields, got %d", n, len(row))
+	}
+	f := make([]float64, n)
+	for i := 0; i < n; i++ {
+		v, err := strconv.ParseFloat(row[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", i, err)
+		}
+		f[i] = v
+	}
+	return f, nil
+}
+
+// get performs a GET request and returns the data rows of the response.
+// Rate limited (429) and server errors are retried with rest.DefaultPolicy.
+func (c *Client) get(path string, params url.Values) ([][]string, error) {
+	var data [][]string
+	_, err := rest.DefaultPolicy.Get(c.httpClient, c.baseURL+path+"?"+params.Encode(), nil, func(resp *rest.Response) (bool, error) {
+		var payload struct {
+			Code string     `json:"code"`
+			Msg  string     `json:"msg"`
+			Data [][]string `json:"data"`
+		}
+		jsonErr := json.Unmarshal(resp.Body, &payload)
+		if resp.StatusCode == http.StatusOK && jsonErr == nil && payload.Code == "0" {
+			data = payload.Data
+			return false, nil
+		}
+		if jsonErr != nil && resp.StatusCode == http.StatusOK {
+			return false, fmt.Errorf("json unmarshal error: %w, body: %s", jsonErr, string(resp.Body))
+		}
+		apiErr := &APIError{StatusCode: resp.StatusCode, Code: payload.Code, Msg: payload.Msg}
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, apiErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,118 @@
+package okx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKlines(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if got := r.URL.Query().Get("instId"); got != "BTC-USDT-SWAP" {
+			t.Errorf("instId = %q", got)
+		}
+		if got := r.URL.Query().Get("bar"); got != "1H" {
+			t.Errorf("bar = %q", got)
+		}
+		// Newest first, as OKX returns them.
+		fmt.Fprint(w, `{"code":"0","msg":"","data":[
+			["3600000","2","3","1","2.5","100","10","25","1"],
+			["0","1","2","0.5","2","200","20","40","1"]]}`)
+	}))
+	defer srv.Close()
+
+	klines, err := NewClient(srv.Client(), srv.URL).Klines("BTCUSDT", "1h", 2)
+	if err != nil {
+		t.Fatalf("Klines: %v", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("got %d requests, want the 503 retried once", got)
+	}
+	if len(klines) != 2 || klines[0].Timestamp != 0 || klines[1].Timestamp != 3600000 {
+		t.Fatalf("klines = %+v, want two oldest first", klines)
+	}
+	if k := klines[1]; k.CloseTime != 7199999 || k.Close != 2.5 || k.Volume != 10 || k.QuoteVolume != 25 {
+		t.Errorf("newest kline = %+v", k)
+	}
+}
+
+func TestAPIErrorNotRetried(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, `{"code":"51001","msg":"Instrument ID does not exist","data":[]}`)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.Client(), srv.URL).Klines("FOOUSDT", "15m", 2)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "51001" {
+		t.Fatalf("err = %v, want *APIError with code 51001", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestBar(t *testing.T) {
+	tests := []struct {
+		interval    string
+		bar         string // "" if unsupported
+		statsPeriod string // "" if unsupported
+	}{
+		{"1m", "1m", ""},
+		{"15m", "15m", "15m"},
+		{"1h", "1H", "1H"},
+		{"4h", "4H", "4H"},
+		// Bars of 6h and longer must open at UTC, like Binance.
+		{"6h", "6Hutc", "6Hutc"},
+		{"12h", "12Hutc", "12Hutc"},
+		{"1d", "1Dutc", "1Dutc"},
+		{"1w", "1Wutc", ""},
+		{"8h", "", ""},
+		{"1M", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		got, err := bar(tt.interval)
+		if tt.bar == "" {
+			if err == nil {
+				t.Errorf("bar(%q) = %q, want an error", tt.interval, got)
+			}
+		} else if err != nil || got != tt.bar {
+			t.Errorf("bar(%q) = %q, %v, want %q", tt.interval, got, err, tt.bar)
+		}
+
+		got, err = statsPeriod(tt.interval)
+		if tt.statsPeriod == "" {
+			if err == nil {
+				t.Errorf("statsPeriod(%q) = %q, want an error", tt.interval, got)
+			}
+		} else if err != nil || got != tt.statsPeriod {
+			t.Errorf("statsPeriod(%q) = %q, %v, want %q", tt.interval, got, err, tt.statsPeriod)
+		}
+	}
+}
+
+func TestUnsupportedIntervalNotRequested(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.Client(), srv.URL).Klines("BTCUSDT", "8h", 2); err == nil {
+		t.Error("Klines(8h) succeeded, want an error")
+	}
+	if got := atomic.LoadInt32(&requests); got != 0 {
+		t.Errorf("got %d requests, want none", got)
+	}
+}
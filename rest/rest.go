@@ -0,0 +1,128 @@
+// Package rest implements the GET-with-retry loop shared by the exchange
+// clients: jittered exponential backoff, Retry-After and a cap on how long a
+// rate limit is waited out. Each client decides through a Check which
+// responses succeed, fail or are worth retrying.
+package rest
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Policy configures the retry loop of Get.
+type Policy struct {
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// MaxRetryAfter gives up instead of waiting out a longer Retry-After,
+	// e.g. an IP ban. 0 waits for any Retry-After.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultPolicy is the retry policy of the exchange clients.
+var DefaultPolicy = Policy{
+	MaxRetries:    3,
+	BaseBackoff:   500 * time.Millisecond,
+	MaxBackoff:    10 * time.Second,
+	MaxRetryAfter: 60 * time.Second,
+}
+
+// Response is a fully read HTTP response.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Check inspects a response. It returns a nil error to accept the response,
+// an error with retry set to try again after a backoff, or an error alone to
+// give up.
+type Check func(resp *Response) (retry bool, err error)
+
+var (
+	rngMu sync.Mutex
+	rng   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Get requests reqURL until check accepts the response, check gives up or
+// the retries are used up. Transport errors are always retried. before, if
+// not nil, runs ahead of every attempt, e.g. to charge a weight budget; its
+// error is returned as is.
+func (p Policy) Get(client *http.Client, reqURL string, before func() error, check Check) ([]byte, error) {
+	var lastErr error
+	var retryAfter time.Duration
+	for attempt := 0; attempt <= p.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(p.Backoff(attempt, retryAfter))
+		}
+		if before != nil {
+			if err := before(); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := client.Get(reqURL)
+		if err != nil {
+			lastErr, retryAfter = err, 0
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr, retryAfter = err, 0
+			continue
+		}
+
+		retry, err := check(&Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body})
+		if err == nil {
+			return body, nil
+		}
+		retryAfter = ParseRetryAfter(resp.Header)
+		if !retry || (p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter) {
+			return nil, err
+		}
+		lastErr = err
+	}
+
+	path := reqURL
+	if u, err := url.Parse(reqURL); err == nil {
+		path = u.Path
+	}
+	return nil, fmt.Errorf("request %s failed after %d retries: %w", path, p.MaxRetries, lastErr)
+}
+
+// Backoff returns how long to wait before the given retry attempt, counted
+// from 1: retryAfter when the server sent one, otherwise an exponential
+// backoff capped at MaxBackoff.
+func (p Policy) Backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	d := p.BaseBackoff << uint(attempt-1)
+	if p.MaxBackoff > 0 && (d > p.MaxBackoff || d <= 0) {
+		d = p.MaxBackoff
+	}
+	if d < 2 {
+		return d
+	}
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	// Jitter: spread retries of concurrent requests over [d/2, d).
+	return d/2 + time.Duration(rng.Int63n(int64(d/2)))
+}
+
+// ParseRetryAfter parses a Retry-After header given in seconds.
+func ParseRetryAfter(h http.Header) time.Duration {
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,145 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fast keeps the real sleeps of retrying tests short.
+var fast = Policy{MaxRetries: 2, BaseBackoff: 2 * time.Millisecond, MaxBackoff: 4 * time.Millisecond, MaxRetryAfter: time.Second}
+
+var errStatus = errors.New("bad status")
+
+// checkStatus accepts 200, retries 429 and 5xx and gives up otherwise.
+func checkStatus(resp *Response) (bool, error) {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("status %d: %w", resp.StatusCode, errStatus)
+	}
+	return false, fmt.Errorf("status %d: %w", resp.StatusCode, errStatus)
+}
+
+// serve answers with the given statuses in turn and 200 "ok" afterwards.
+func serve(t *testing.T, header http.Header, statuses ...int) (*httptest.Server, *int32) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&requests, 1))
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		header   http.Header
+		requests int32
+		wantErr  string
+	}{
+		{"ok", nil, nil, 1, ""},
+		{"retried", []int{503, 429}, nil, 3, ""},
+		{"gives up", []int{400}, nil, 1, "status 400"},
+		{"retries used up", []int{500, 500, 500}, nil, 3, "request /data failed after 2 retries: status 500"},
+		{"retry after too long", []int{429}, http.Header{"Retry-After": {"120"}}, 1, "status 429"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := serve(t, tt.header, tt.statuses...)
+			body, err := fast.Get(srv.Client(), srv.URL+"/data?x=1", nil, checkStatus)
+			if got := atomic.LoadInt32(requests); got != tt.requests {
+				t.Errorf("got %d requests, want %d", got, tt.requests)
+			}
+			if tt.wantErr == "" {
+				if err != nil || string(body) != "ok" {
+					t.Errorf("Get = %q, %v, want ok", body, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.Is(err, errStatus) {
+				t.Errorf("err = %v, want one wrapping %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetBefore(t *testing.T) {
+	srv, requests := serve(t, nil, 503)
+	calls := 0
+	errBudget := errors.New("budget")
+	_, err := fast.Get(srv.Client(), srv.URL, func() error {
+		if calls++; calls > 1 {
+			return errBudget
+		}
+		return nil
+	}, checkStatus)
+	if !errors.Is(err, errBudget) {
+		t.Errorf("err = %v, want the error of before", err)
+	}
+	if got := atomic.LoadInt32(requests); got != 1 {
+		t.Errorf("got %d requests, want 1", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := DefaultPolicy
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{1, 0, p.BaseBackoff / 2, p.BaseBackoff},
+		{2, 0, p.BaseBackoff, 2 * p.BaseBackoff},
+		{3, 0, 2 * p.BaseBackoff, 4 * p.BaseBackoff},
+		{10, 0, p.MaxBackoff / 2, p.MaxBackoff},
+		{70, 0, p.MaxBackoff / 2, p.MaxBackoff},
+		{1, 7 * time.Second, 7 * time.Second, 7 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := p.Backoff(tt.attempt, tt.retryAfter)
+			if got < tt.min || got > tt.max {
+				t.Errorf("Backoff(%d, %v) = %v, want within [%v, %v]", tt.attempt, tt.retryAfter, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"5", 5 * time.Second},
+		{"-1", 0},
+		{"Wed, 21 Oct 2015 07:28:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.header != "" {
+			h.Set("Retry-After", tt.header)
+		}
+		if got := ParseRetryAfter(h); got != tt.want {
+			t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package store
+
+import (
+	"binance-monitor/models"
+	"binance-monitor/strategy"
+	"time"
+)
+
+var (
+	_ strategy.MarketDataSource  = (*Source)(nil)
+	_ strategy.TakerVolumeSource = (*Source)(nil)
+	_ strategy.TopTraderSource   = (*Source)(nil)
+	_ strategy.FundingSource     = (*Source)(nil)
+	_ strategy.DepthSource       = (*Source)(nil)
+	_ strategy.BasisSource       = (*Source)(nil)
+	_ strategy.SpotSource        = (*Source)(nil)
+	_ strategy.WeightBudget      = (*Source)(nil)
+)
+
+// Series names of the stored series.
+const (
+	KlinesSeries           = "klines"
+	OpenInterestSeries     = "open_interest"
+	LongShortRatioSeries   = "long_short_ratio"
+	TakerRatioSeries       = "taker_ratio"
+	TopAccountRatioSeries  = "top_account_ratio"
+	TopPositionRatioSeries = "top_position_ratio"
+	SpotKlinesSeries       = "spot_klines"
+	IndexKlinesSeries      = "index_klines"
+)
+
+// PageSize is the most periods Source requests with a single limit, the page
+// size of the Binance statistics endpoints and the smallest page of the
+// stored series. Longer gaps are walked with a RangeSource.
+const PageSize = 500
+
+// RangeSource is implemented by sources that can fetch the points of a series
+// within a time range of any length, such as binance.Client. Source uses it to
+// fill gaps longer than PageSize periods; without it such a gap restarts the
+// stored series.
+type RangeSource interface {
+	KlinesRange(symbol, interval string, start, end time.Time) ([]models.KlineData, error)
+	OpenInterestRange(symbol, period string, start, end time.Time) ([]models.BinanceOI, error)
+	LongShortRatioRange(symbol, period string, start, end time.Time) ([]models.GlobalLongShortRatio, error)
+	TakerLongShortRatioRange(symbol, period string, start, end time.Time) ([]models.TakerLongShortRatio, error)
+	TopLongShortAccountRatioRange(symbol, period string, start, end time.Time) ([]models.TopLongShortRatio, error)
+	TopLongShortPositionRatioRange(symbol, period string, start, end time.Time) ([]models.TopLongShortRatio, error)
+}
+
+// Source is a strategy.MarketDataSource that keeps the periodic series of
+// another source in a Store. Each call only requests the periods newer than
+// the last stored point and serves the rest from disk, so limit may exceed
+// what one API request can return once the history has been stored, e.g.
+// with Seed. Requests never ask for more than PageSize periods; longer gaps
+// are walked when the wrapped source is a RangeSource.
+//
+// Closed klines and statistics are stored; a kline that is still forming is
+// returned but never stored. Snapshots (funding, premium index, depth and
+// delivery contracts) are passed through unchanged. Optional
+// interfaces the wrapped source does not implement fail with
+// strategy.ErrNotSupported.
+type Source struct {
+	store *Store
+	src   strategy.MarketDataSource
+	now   func() time.Time
+}
+
+// NewSource wraps src with store.
+func NewSource(store *Store, src strategy.MarketDataSource) *Source {
+	return &Source{store: store, src: src, now: time.Now}
+}
+
+// SetClock sets the clock that decides which klines have closed and how many
+// periods are missing since the last stored point. It defaults to time.Now.
+func (s *Source) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+// Exchange implements strategy.MarketDataSource.
+func (s *Source) Exchange() string {
+	return s.src.Exchange()
+}
+
+// RemainingWeight implements strategy.WeightBudget for the wrapped source. It
+// returns -1 (no budget) if the wrapped source has none.
+func (s *Source) RemainingWeight() int {
+	if wb, ok := s.src.(strategy.WeightBudget); ok {
+		return wb.RemainingWeight()
+	}
+	return -1
+}
+
+// FetchWeight implements strategy.WeightBudget. It reports the weight of the
+// wrapped source, which overestimates the stored series since only their
+// newest periods are requested.
+func (s *Source) FetchWeight(limit int) (required, optional int) {
+	if wb, ok := s.src.(strategy.WeightBudget); ok {
+		return wb.FetchWeight(limit)
+	}
+	return 0, 0
+}
+
+// Seed stores the series of data, typically the result of a backfill, so
+// later calls can serve a long history from disk. Series are given as the
+// API reports them; klines that are still forming are skipped.
+func (s *Source) Seed(data strategy.MarketData, interval string) error {
+	period, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return err
+	}
+	key := func(series string) Key {
+		return Key{Source: data.Exchange, Symbol: data.Symbol, Series: series, Interval: interval}
+	}
+
+	closed, _ := strategy.SplitClosedKlines(data.Klines, period, s.now())
+	if _, err := Append(s.store, key(KlinesSeries), closed, klineTime); err != nil {
+		return err
+	}
+	if _, err := Append(s.store, key(OpenInterestSeries), data.OIs, func(p models.BinanceOI) int64 { return p.Timestamp }); err != nil {
+		return err
+	}
+	if _, err := Append(s.store, key(LongShortRatioSeries), data.LSRatios, func(p models.GlobalLongShortRatio) int64 { return p.Timestamp }); err != nil {
+		return err
+	}
+	if _, err := Append(s.store, key(TakerRatioSeries), data.TakerRatios, func(p models.TakerLongShortRatio) int64 { return p.Timestamp }); err != nil {
+		return err
+	}
+	if _, err := Append(s.store, key(TopAccountRatioSeries), data.TopAccountRatios, topRatioTime); err != nil {
+		return err
+	}
+	_, err = Append(s.store, key(TopPositionRatioSeries), data.TopPositionRatios, topRatioTime)
+	return err
+}
+
+// Klines implements strategy.MarketDataSource.
+func (s *Source) Klines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	return s.klines(KlinesSeries, symbol, interval, limit, s.src.Klines, byRange(s, func(rs RangeSource, start, end time.Time) ([]models.KlineData, error) {
+		return rs.KlinesRange(symbol, interval, start, end)
+	}))
+}
+
+// OpenInterest implements strategy.MarketDataSource.
+func (s *Source) OpenInterest(symbol, period string, limit int) ([]models.BinanceOI, error) {
+	return delta(s, s.key(OpenInterestSeries, symbol, period), limit, func(n int) ([]models.BinanceOI, error) {
+		return s.src.OpenInterest(symbol, period, n)
+	}, byRange(s, func(rs RangeSource, start, end time.Time) ([]models.BinanceOI, error) {
+		return rs.OpenInterestRange(symbol, period, start, end)
+	}), func(p models.BinanceOI) int64 { return p.Timestamp }, nil)
+}
+
+// LongShortRatio implements strategy.MarketDataSource.
+func (s *Source) LongShortRatio(symbol, period string, limit int) ([]models.GlobalLongShortRatio, error) {
+	return delta(s, s.key(LongShortRatioSeries, symbol, period), limit, func(n int) ([]models.GlobalLongShortRatio, error) {
+		return s.src.LongShortRatio(symbol, period, n)
+	}, byRange(s, func(rs RangeSource, start, end time.Time) ([]models.GlobalLongShortRatio, error) {
+		return rs.LongShortRatioRange(symbol, period, start, end)
+	}), func(p models.GlobalLongShortRatio) int64 { return p.Timestamp }, nil)
+}
+
+// TakerLongShortRatio implements strategy.TakerVolumeSource.
+func (s *Source) TakerLongShortRatio(symbol, period string, limit int) ([]models.TakerLongShortRatio, error) {
+	ts, ok := s.src.(strategy.TakerVolumeSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return delta(s, s.key(TakerRatioSeries, symbol, period), limit, func(n int) ([]models.TakerLongShortRatio, error) {
+		return ts.TakerLongShortRatio(symbol, period, n)
+	}, byRange(s, func(rs RangeSource, start, end time.Time) ([]models.TakerLongShortRatio, error) {
+		return rs.TakerLongShortRatioRange(symbol, period, start, end)
+	}), func(p models.TakerLongShortRatio) int64 { return p.Timestamp }, nil)
+}
+
+// TopLongShortAccountRatio implements strategy.TopTraderSource.
+func (s *Source) TopLongShortAccountRatio(symbol, period string, limit int) ([]models.TopLongShortRatio, error) {
+	ts, ok := s.src.(strategy.TopTraderSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return delta(s, s.key(TopAccountRatioSeries, symbol, period), limit, func(n int) ([]models.TopLongShortRatio, error) {
+		return ts.TopLongShortAccountRatio(symbol, period, n)
+	}, byRange(s, func(rs RangeSource, start, end time.Time) ([]models.TopLongShortRatio, error) {
+		return rs.TopLongShortAccountRatioRange(symbol, period, start, end)
+	}), topRatioTime, nil)
+}
+
+// TopLongShortPositionRatio implements strategy.TopTraderSource.
+func (s *Source) TopLongShortPositionRatio(symbol, period string, limit int) ([]models.TopLongShortRatio, error) {
+	ts, ok := s.src.(strategy.TopTraderSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return delta(s, s.key(TopPositionRatioSeries, symbol, period), limit, func(n int) ([]models.TopLongShortRatio, error) {
+		return ts.TopLongShortPositionRatio(symbol, period, n)
+	}, byRange(s, func(rs RangeSource, start, end time.Time) ([]models.TopLongShortRatio, error) {
+		return rs.TopLongShortPositionRatioRange(symbol, period, start, end)
+	}), topRatioTime, nil)
+}
+
+// SpotKlines implements strategy.SpotSource.
+func (s *Source) SpotKlines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	ss, ok := s.src.(strategy.SpotSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return s.klines(SpotKlinesSeries, symbol, interval, limit, ss.SpotKlines, nil)
+}
+
+// IndexPriceKlines implements strategy.BasisSource.
+func (s *Source) IndexPriceKlines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	bs, ok := s.src.(strategy.BasisSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return s.klines(IndexKlinesSeries, symbol, interval, limit, bs.IndexPriceKlines, nil)
+}
+
+// DeliveryContracts implements strategy.BasisSource.
+func (s *Source) DeliveryContracts(symbol, interval string, limit int) ([]models.DeliveryContract, error) {
+	bs, ok := s.src.(strategy.BasisSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return bs.DeliveryContracts(symbol, interval, limit)
+}
+
+// FundingRates implements strategy.FundingSource.
+func (s *Source) FundingRates(symbol string, limit int) ([]models.FundingRate, error) {
+	fs, ok := s.src.(strategy.FundingSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return fs.FundingRates(symbol, limit)
+}
+
+// PremiumIndex implements strategy.FundingSource.
+func (s *Source) PremiumIndex(symbol string) (*models.PremiumIndex, error) {
+	fs, ok := s.src.(strategy.FundingSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return fs.PremiumIndex(symbol)
+}
+
+// Depth implements strategy.DepthSource.
+func (s *Source) Depth(symbol string, limit int) (*models.OrderBook, error) {
+	ds, ok := s.src.(strategy.DepthSource)
+	if !ok {
+		return nil, strategy.ErrNotSupported
+	}
+	return ds.Depth(symbol, limit)
+}
+
+func (s *Source) key(series, symbol, interval string) Key {
+	return Key{Source: s.src.Exchange(), Symbol: symbol, Series: series, Interval: interval}
+}
+
+// klines serves a kline series, storing only closed klines. fetchRange may be
+// nil, see delta.
+func (s *Source) klines(series, symbol, interval string, limit int, fetch func(symbol, interval string, limit int) ([]models.KlineData, error), fetchRange func(start, end time.Time) ([]models.KlineData, error)) ([]models.KlineData, error) {
+	period, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	closed := func(k models.KlineData) bool {
+		closed, _ := strategy.SplitClosedKlines([]models.KlineData{k}, period, now)
+		return len(closed) == 1
+	}
+	return delta(s, s.key(series, symbol, interval), limit, func(n int) ([]models.KlineData, error) {
+		return fetch(symbol, interval, n)
+	}, fetchRange, klineTime, closed)
+}
+
+// delta returns the newest limit points of a series, or as many as the store
+// and the source can provide. Only the periods since the last stored point
+// are fetched, at most PageSize at a time: a longer gap is walked with
+// fetchRange when the source supports it, and an empty store is filled with
+// the newest limit periods the same way. Fetched points for which final
+// reports true (all of them if final is nil) are stored; the remaining ones,
+// such as a live kline, are returned after the stored ones.
+//
+// Points are only appended when they continue the stored series. Otherwise,
+// e.g. after a gap the source cannot page through, the stored series is
+// replaced with the fetched points rather than left with a hole.
+func delta[T any](s *Source, key Key, limit int, fetch func(n int) ([]T, error), fetchRange func(start, end time.Time) ([]T, error), timestamp func(T) int64, final func(T) bool) ([]T, error) {
+	period, err := strategy.ParseInterval(key.Interval)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := Load[T](s.store, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	n, start := limit, now.Add(-time.Duration(limit)*period)
+	if len(stored) > 0 {
+		last := time.UnixMilli(timestamp(stored[len(stored)-1]))
+		n, start = int(now.Sub(last)/period), last.Add(time.Millisecond)
+		if n < 1 {
+			n = 1
+		}
+	}
+
+	var fetched []T
+	switch {
+	case n <= PageSize:
+		fetched, err = fetch(n)
+	case fetchRange != nil:
+		fetched, err = fetchRange(start, now)
+	default:
+		fetched, err = fetch(PageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	done, pending := fetched, []T(nil)
+	if final != nil {
+		for i, p := range fetched {
+			if !final(p) {
+				done, pending = fetched[:i], fetched[i:]
+				break
+			}
+		}
+	}
+
+	var history []T
+	if len(stored) == 0 || len(done) == 0 || timestamp(done[0]) <= timestamp(stored[len(stored)-1])+period.Milliseconds() {
+		if history, err = Append(s.store, key, done, timestamp); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := Replace(s.store, key, done); err != nil {
+			return nil, err
+		}
+		history = done
+	}
+	if len(pending) > limit {
+		pending = pending[len(pending)-limit:]
+	}
+	if keep := limit - len(pending); len(history) > keep {
+		history = history[len(history)-keep:]
+	}
+	// history may be shared with the store's cache, so return a copy.
+	out := make([]T, 0, len(history)+len(pending))
+	return append(append(out, history...), pending...), nil
+}
+
+// byRange adapts a RangeSource method for delta. It returns nil if the wrapped
+// source is not a RangeSource.
+func byRange[T any](s *Source, fetch func(rs RangeSource, start, end time.Time) ([]T, error)) func(start, end time.Time) ([]T, error) {
+	rs, ok := s.src.(RangeSource)
+	if !ok {
+		return nil
+	}
+	return func(start, end time.Time) ([]T, error) {
+		return fetch(rs, start, end)
+	}
+}
+
+func klineTime(k models.KlineData) int64 { return k.Timestamp }
+
+func topRatioTime(p models.TopLongShortRatio) int64 { return p.Timestamp }
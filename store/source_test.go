@@ -0,0 +1,170 @@
+package store
+
+import (
+	"binance-monitor/binance"
+	"binance-monitor/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var _ RangeSource = (*binance.Client)(nil)
+
+const testPeriod = 15 * time.Minute
+
+// fakeSource serves one open interest snapshot at the close of every period
+// up to now and, like the Binance statistics endpoints, rejects a limit above
+// 500.
+type fakeSource struct {
+	now    time.Time
+	limits []int
+}
+
+func (f *fakeSource) Exchange() string { return "fake" }
+
+func (f *fakeSource) Klines(symbol, interval string, limit int) ([]models.KlineData, error) {
+	return nil, nil
+}
+
+func (f *fakeSource) LongShortRatio(symbol, period string, limit int) ([]models.GlobalLongShortRatio, error) {
+	return nil, nil
+}
+
+func (f *fakeSource) OpenInterest(symbol, period string, limit int) ([]models.BinanceOI, error) {
+	f.limits = append(f.limits, limit)
+	if limit > 500 {
+		return nil, fmt.Errorf("limit %d above the maximum of 500", limit)
+	}
+	newest := f.now.Truncate(testPeriod)
+	return f.between(newest.Add(-time.Duration(limit-1)*testPeriod), newest), nil
+}
+
+// between returns the snapshots within [start, end].
+func (f *fakeSource) between(start, end time.Time) []models.BinanceOI {
+	var ois []models.BinanceOI
+	step := testPeriod.Milliseconds()
+	first := (start.UnixMilli() + step - 1) / step * step
+	for ts := first; ts <= end.UnixMilli() && ts <= f.now.UnixMilli(); ts += step {
+		ois = append(ois, models.BinanceOI{Symbol: "BTCUSDT", SumOpenInterest: "1", Timestamp: ts})
+	}
+	return ois
+}
+
+// rangeSource adds a RangeSource to fakeSource.
+type rangeSource struct {
+	fakeSource
+	ranges int
+}
+
+func (f *rangeSource) KlinesRange(symbol, interval string, start, end time.Time) ([]models.KlineData, error) {
+	return nil, nil
+}
+
+func (f *rangeSource) OpenInterestRange(symbol, period string, start, end time.Time) ([]models.BinanceOI, error) {
+	f.ranges++
+	return f.between(start, end), nil
+}
+
+func (f *rangeSource) LongShortRatioRange(symbol, period string, start, end time.Time) ([]models.GlobalLongShortRatio, error) {
+	return nil, nil
+}
+
+func (f *rangeSource) TakerLongShortRatioRange(symbol, period string, start, end time.Time) ([]models.TakerLongShortRatio, error) {
+	return nil, nil
+}
+
+func (f *rangeSource) TopLongShortAccountRatioRange(symbol, period string, start, end time.Time) ([]models.TopLongShortRatio, error) {
+	return nil, nil
+}
+
+func (f *rangeSource) TopLongShortPositionRatioRange(symbol, period string, start, end time.Time) ([]models.TopLongShortRatio, error) {
+	return nil, nil
+}
+
+// checkContiguous fails unless points are one period apart and end at end.
+func checkContiguous(t *testing.T, name string, points []models.BinanceOI, end time.Time) {
+	t.Helper()
+	if len(points) == 0 {
+		t.Fatalf("%s: no points", name)
+	}
+	for i := 1; i < len(points); i++ {
+		if d := points[i].Timestamp - points[i-1].Timestamp; d != testPeriod.Milliseconds() {
+			t.Fatalf("%s: points %d and %d are %s apart", name, i-1, i, time.Duration(d)*time.Millisecond)
+		}
+	}
+	if last := points[len(points)-1].Timestamp; last != end.UnixMilli() {
+		t.Errorf("%s: ends at %d, want %d", name, last, end.UnixMilli())
+	}
+}
+
+func TestOpenInterestDelta(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 5, 0, 0, time.UTC)
+	newest := now.Truncate(testPeriod)
+
+	tests := []struct {
+		name      string
+		ranges    bool
+		stored    int           // stored points, 0 for an empty store
+		age       time.Duration // age of the newest stored point
+		limit     int
+		wantLen   int  // points returned
+		wantRange bool // whether the gap is walked by range
+	}{
+		// The store holds fewer points than limit: only the new periods are
+		// requested instead of limit, which is above the page size.
+		{name: "short store, large limit", stored: 100, age: 2 * testPeriod, limit: 2881, wantLen: 102},
+		{name: "full store", stored: 600, age: 3 * testPeriod, limit: 96, wantLen: 96},
+		// A gap longer than limit and the page size is walked by range.
+		{name: "long gap with range", ranges: true, stored: 100, age: 1000 * testPeriod, limit: 96, wantLen: 96, wantRange: true},
+		{name: "empty store with range", ranges: true, limit: 2881, wantLen: 2881, wantRange: true},
+		// Without range support the series restarts rather than keeping a hole.
+		{name: "long gap without range", stored: 100, age: 1000 * testPeriod, limit: 2881, wantLen: 500},
+		{name: "empty store without range", limit: 2881, wantLen: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, err := Open(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			fake := &rangeSource{fakeSource: fakeSource{now: now}}
+			src := NewSource(st, &fake.fakeSource)
+			if tt.ranges {
+				src = NewSource(st, fake)
+			}
+			src.SetClock(func() time.Time { return now })
+
+			key := Key{Source: "fake", Symbol: "BTCUSDT", Series: OpenInterestSeries, Interval: "15m"}
+			if tt.stored > 0 {
+				end := newest.Add(-tt.age)
+				seed := fake.between(end.Add(-time.Duration(tt.stored-1)*testPeriod), end)
+				if _, err := Append(st, key, seed, func(p models.BinanceOI) int64 { return p.Timestamp }); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := src.OpenInterest("BTCUSDT", "15m", tt.limit)
+			if err != nil {
+				t.Fatalf("OpenInterest: %v", err)
+			}
+			for _, limit := range fake.limits {
+				if limit > PageSize {
+					t.Errorf("requested limit %d, above PageSize", limit)
+				}
+			}
+			if (fake.ranges > 0) != tt.wantRange {
+				t.Errorf("range requests = %d, want range %v", fake.ranges, tt.wantRange)
+			}
+			if len(got) != tt.wantLen {
+				t.Errorf("got %d points, want %d", len(got), tt.wantLen)
+			}
+			checkContiguous(t, "result", got, newest)
+
+			stored, err := Load[models.BinanceOI](st, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkContiguous(t, "store", stored, newest)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Package store is an embedded, file-based time-series store. Each series is
+// an append-only log of JSON lines, one point per line, laid out as
+// <dir>/<source>/<symbol>/<interval>/<series>.jsonl, e.g.
+// data/binance/BTCUSDT/15m/klines.jsonl.
+//
+// Source wraps a strategy.MarketDataSource so that only periods newer than the
+// last stored point are requested from the API and the rest of the history is
+// read from disk.
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Key identifies one stored series.
+type Key struct {
+	Source   string // venue, e.g. "binance"
+	Symbol   string
+	Series   string // e.g. "klines", "open_interest"
+	Interval string
+}
+
+// Store reads and appends series under a directory. A Store is safe for
+// concurrent use. It keeps every series it has read in memory, so after the
+// first Load of a series only new points touch the disk; nothing else may
+// modify the logs while the Store is open.
+type Store struct {
+	dir string
+
+	mu     sync.Mutex
+	series map[Key]*series
+}
+
+// series is the cached state of one log, guarded by mu. Once loaded, points
+// holds the decoded points as a []T of the type the log was last read as,
+// and size the length of the log's complete lines.
+type series struct {
+	mu     sync.Mutex
+	loaded bool
+	points any
+	size   int64
+}
+
+// Open opens the store in dir, creating the directory if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &Store{dir: dir, series: map[Key]*series{}}, nil
+}
+
+// path returns the log file of a key.
+func (s *Store) path(key Key) (string, error) {
+	for _, part := range []string{key.Source, key.Symbol, key.Series, key.Interval} {
+		if part == "" || strings.ContainsAny(part, `/\.`) {
+			return "", fmt.Errorf("invalid store key %+v", key)
+		}
+	}
+	return filepath.Join(s.dir, key.Source, key.Symbol, key.Interval, key.Series+".jsonl"), nil
+}
+
+// get returns the cached state of the series of key.
+func (s *Store) get(key Key) *series {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ser, ok := s.series[key]
+	if !ok {
+		ser = &series{}
+		s.series[key] = ser
+	}
+	return ser
+}
+
+// Load returns all points of a series, oldest first. A missing series is
+// empty. A truncated last line, left by a write interrupted mid-way, is
+// ignored and overwritten by the next Append; any other malformed line is an
+// error. The returned slice is shared with the cache and must not be
+// modified; appending to it is safe.
+func Load[T any](s *Store, key Key) ([]T, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	ser := s.get(key)
+	ser.mu.Lock()
+	defer ser.mu.Unlock()
+	points, err := loadCached[T](ser, file)
+	return points[:len(points):len(points)], err
+}
+
+// loadCached returns the cached points of ser, reading them from file on
+// first use or when they were cached as another type. ser.mu must be held.
+func loadCached[T any](ser *series, file string) ([]T, error) {
+	if ser.loaded {
+		if points, ok := ser.points.([]T); ok {
+			return points, nil
+		}
+	}
+	points, size, err := load[T](file)
+	if err != nil {
+		return nil, err
+	}
+	ser.loaded, ser.points, ser.size = true, points, size
+	return points, nil
+}
+
+// load reads a log file and returns its points and the size of its complete
+// lines, which excludes a truncated last line.
+func load[T any](file string) ([]T, int64, error) {
+	body, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size := int64(bytes.LastIndexByte(body, '\n') + 1)
+	var points []T
+	for i, line := range bytes.Split(body[:size], []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var p T
+		if err := json.Unmarshal(line, &p); err != nil {
+			return nil, 0, fmt.Errorf("corrupt line %d of %s: %w", i+1, file, err)
+		}
+		points = append(points, p)
+	}
+	return points, size, nil
+}
+
+// Append appends the points that are newer than the last stored point, so
+// overlapping fetches can be appended without duplicating data. points must
+// be sorted oldest first. It returns the full series after the append, which
+// like the result of Load must not be modified.
+func Append[T any](s *Store, key Key, points []T, timestamp func(T) int64) ([]T, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	ser := s.get(key)
+	ser.mu.Lock()
+	defer ser.mu.Unlock()
+
+	stored, err := loadCached[T](ser, file)
+	if err != nil {
+		return nil, err
+	}
+	size := ser.size
+	last := int64(-1 << 63)
+	if n := len(stored); n > 0 {
+		last = timestamp(stored[n-1])
+	}
+
+	var buf bytes.Buffer
+	for _, p := range points {
+		if timestamp(p) <= last {
+			continue
+		}
+		line, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		stored = append(stored, p)
+		last = timestamp(p)
+	}
+	if buf.Len() == 0 {
+		return stored[:len(stored):len(stored)], nil
+	}
+
+	if err := appendLog(file, size, buf.Bytes()); err != nil {
+		// The log may hold part of the batch; read it again next time.
+		ser.loaded = false
+		return nil, err
+	}
+	ser.points, ser.size = stored, size+int64(buf.Len())
+	return stored[:len(stored):len(stored)], nil
+}
+
+// appendLog writes lines to file at offset size, overwriting a truncated last
+// line instead of appending after it.
+func appendLog(file string, size int64, lines []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create store directory: %w", err)
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(size, 0); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Replace overwrites a series with points, sorted oldest first. Source uses it
+// when new points cannot be appended without leaving a hole in the series.
+// The new log is written next to the old one and renamed over it, so an
+// interrupted Replace leaves the old series intact.
+func Replace[T any](s *Store, key Key, points []T) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	ser := s.get(key)
+	ser.mu.Lock()
+	defer ser.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, p := range points {
+		line, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create store directory: %w", err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return err
+	}
+	ser.loaded, ser.points, ser.size = true, append([]T(nil), points...), int64(buf.Len())
+	return nil
+}
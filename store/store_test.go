@@ -0,0 +1,81 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type point struct {
+	T int64 `json:"t"`
+}
+
+func pointTime(p point) int64 { return p.T }
+
+func TestStoreServesCachedSeries(t *testing.T) {
+	dir := t.TempDir()
+	key := Key{Source: "fake", Symbol: "BTCUSDT", Series: "points", Interval: "15m"}
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Append(s, key, []point{{1}, {2}}, pointTime); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	// Corrupt the log behind the store's back: a Store that re-read it
+	// would fail from now on.
+	file := filepath.Join(dir, "fake", "BTCUSDT", "15m", "points.jsonl")
+	if err := os.WriteFile(file, []byte("corrupt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load[point](s, key)
+	if err != nil || len(loaded) != 2 {
+		t.Fatalf("Load = %v, %v, want the 2 cached points", loaded, err)
+	}
+	all, err := Append(s, key, []point{{2}, {3}}, pointTime)
+	if err != nil || len(all) != 3 || all[2].T != 3 {
+		t.Fatalf("Append = %v, %v, want the cached points plus {3}", all, err)
+	}
+	if len(loaded) != 2 {
+		t.Errorf("earlier Load result grew to %v", loaded)
+	}
+
+	fresh, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load[point](fresh, key); err == nil {
+		t.Error("Load from a new Store succeeded, want the corrupt line reported")
+	}
+}
+
+func TestStoreReplaceUpdatesCache(t *testing.T) {
+	dir := t.TempDir()
+	key := Key{Source: "fake", Symbol: "BTCUSDT", Series: "points", Interval: "15m"}
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Append(s, key, []point{{1}, {2}}, pointTime); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := Replace(s, key, []point{{10}}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	all, err := Append(s, key, []point{{11}}, pointTime)
+	if err != nil || len(all) != 2 || all[0].T != 10 || all[1].T != 11 {
+		t.Fatalf("Append = %v, %v, want [{10} {11}]", all, err)
+	}
+
+	// The log on disk matches the cache.
+	fresh, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := Load[point](fresh, key)
+	if err != nil || len(stored) != 2 || stored[0].T != 10 || stored[1].T != 11 {
+		t.Fatalf("Load = %v, %v, want [{10} {11}]", stored, err)
+	}
+}
@@ -0,0 +1,309 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AggregateExchange 是跨交易所聚合信号的 Exchange 名称
+const AggregateExchange = "aggregate"
+
+// quoteAssets 是识别基础币种时剥离的计价币种
+var quoteAssets = []string{"USDT", "USDC", "BUSD", "USD"}
+
+// AggregatePoint 代表跨交易所聚合序列中的一个时间点 (USD 名义价值)
+type AggregatePoint struct {
+	Timestamp int64
+	Total     float64
+	ByVenue   map[string]float64
+	Price     float64 // 参考价格, 取第一个交易所的收盘价
+}
+
+// AggregateData 代表同一币种在多个交易所的聚合持仓量与成交额
+// 只保留所有交易所都有数据的时间点, 避免某一交易所缺数造成虚假跳变。
+type AggregateData struct {
+	Asset        string
+	Venues       []string
+	OpenInterest []AggregatePoint
+	Volume       []AggregatePoint
+}
+
+// BaseAsset 返回交易对的基础币种, 例如 BTCUSDT -> BTC
+func BaseAsset(symbol string) string {
+	for _, quote := range quoteAssets {
+		if base := strings.TrimSuffix(symbol, quote); base != symbol && base != "" {
+			return base
+		}
+	}
+	return symbol
+}
+
+// BuildAggregate 将同一币种在各交易所的持仓量与成交量换算为 USD 名义价值并求和
+// 持仓价值优先使用交易所提供的 SumOpenInterestValue, 否则按同期收盘价换算;
+// 成交额取K线成交额, 数据源未提供时按成交量乘以收盘价估算; 补齐的K线 (Synthetic)
+// 不计入, 使该时间点因数据不全而被跳过。
+// 交易所以 MarketData.Exchange 区分, 同一交易所的多个交易对 (如 BTCUSDT 与 BTCUSDC)
+// 只取第一个, 见 distinctVenues。
+func BuildAggregate(asset string, venues []MarketData) AggregateData {
+	venues = distinctVenues(venues)
+	agg := AggregateData{Asset: asset}
+	oiByVenue := make([]map[int64]float64, len(venues))
+	volByVenue := make([]map[int64]float64, len(venues))
+	priceByTime := map[int64]float64{}
+
+	for i, data := range venues {
+		agg.Venues = append(agg.Venues, data.Exchange)
+
+		oiByVenue[i] = make(map[int64]float64, len(data.OIs))
+		for _, oi := range data.OIs {
+			value, err := strconv.ParseFloat(oi.SumOpenInterestValue, 64)
+			if err != nil || value <= 0 {
+				amount, _ := strconv.ParseFloat(oi.SumOpenInterest, 64)
+				value = amount * closeAt(data.Klines, oi.Timestamp)
+			}
+			if value > 0 {
+				oiByVenue[i][oi.Timestamp] = value
+			}
+		}
+
+		volByVenue[i] = make(map[int64]float64, len(data.Klines))
+		for _, k := range data.Klines {
+			if !k.Synthetic {
+				volByVenue[i][k.Timestamp] = KlineNotional(k)
+			}
+			if i == 0 {
+				priceByTime[k.Timestamp] = k.Close
+			}
+		}
+	}
+
+	agg.OpenInterest = mergeVenues(agg.Venues, oiByVenue, priceByTime)
+	agg.Volume = mergeVenues(agg.Venues, volByVenue, priceByTime)
+	return agg
+}
+
+// distinctVenues 按交易所去重, 同一交易所只保留第一个交易对的数据
+// 同一交易所上不同计价币种的合约并非独立的市场来源, 求和会重复计入同一交易所。
+func distinctVenues(venues []MarketData) []MarketData {
+	seen := make(map[string]bool, len(venues))
+	var distinct []MarketData
+	for _, data := range venues {
+		if seen[data.Exchange] {
+			continue
+		}
+		seen[data.Exchange] = true
+		distinct = append(distinct, data)
+	}
+	return distinct
+}
+
+// closeAt 返回开盘时间不晚于 ts 的最后一根K线的收盘价, 没有时返回 0
+func closeAt(klines []models.KlineData, ts int64) float64 {
+	idx := sort.Search(len(klines), func(i int) bool { return klines[i].Timestamp > ts }) - 1
+	if idx < 0 {
+		return 0
+	}
+	return klines[idx].Close
+}
+
+// mergeVenues 按时间戳合并各交易所序列, 只保留所有交易所都有数据的时间点
+func mergeVenues(venues []string, series []map[int64]float64, priceByTime map[int64]float64) []AggregatePoint {
+	if len(series) == 0 {
+		return nil
+	}
+
+	var timestamps []int64
+	for ts := range series[0] {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	var points []AggregatePoint
+	for _, ts := range timestamps {
+		p := AggregatePoint{Timestamp: ts, ByVenue: make(map[string]float64, len(venues)), Price: priceByTime[ts]}
+		complete := true
+		for i, s := range series {
+			v, ok := s[ts]
+			if !ok {
+				complete = false
+				break
+			}
+			p.ByVenue[venues[i]] = v
+			p.Total += v
+		}
+		if complete {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// AnalyzeAggregate 在聚合序列上运行成交量与持仓量检测器
+// 返回用于构建 AI 上下文的合成 MarketData 以及附带主导交易所信息的信号。
+func AnalyzeAggregate(agg AggregateData) (MarketData, []models.Signal) {
+	data := MarketData{Symbol: agg.Asset, Exchange: AggregateExchange}
+	for _, p := range agg.Volume {
+		data.Klines = append(data.Klines, models.KlineData{
+			Symbol:    agg.Asset,
+			Timestamp: p.Timestamp,
+			Open:      p.Price,
+			High:      p.Price,
+			Low:       p.Price,
+			Close:     p.Price,
+			Volume:    p.Total,
+		})
+	}
+	for _, p := range agg.OpenInterest {
+		value := strconv.FormatFloat(p.Total, 'f', 2, 64)
+		data.OIs = append(data.OIs, models.BinanceOI{
+			Symbol:               agg.Asset,
+			SumOpenInterest:      value,
+			SumOpenInterestValue: value,
+			Timestamp:            p.Timestamp,
+		})
+	}
+
+	// 检测器的启用规则同样适用于聚合数据, 交易对为币种本身 (如 "BTC")
+	// 参数同样按币种本身取自 ActiveConfig
+	cfg := ActiveConfig().For(agg.Asset)
+	var signals []models.Signal
+	if DefaultRegistry.Enabled("volume", agg.Asset) {
+		for _, s := range one(DetectVolumeSignal(data.Klines, cfg.Volume), cfg.Volume) {
+			driver, shares := volumeDriver(agg.Volume)
+			signals = append(signals, withDriver(s, driver, shares))
+		}
+	}
+	var oiSignals []models.Signal
+	if DefaultRegistry.Enabled("open_interest", agg.Asset) {
+		oiSignals = all(DetectOpenInterestSignal(data.OIs, data.Klines, cfg.OpenInterest), cfg.OpenInterest)
+	}
+	for _, s := range oiSignals {
+		window := 1
+		switch {
+		case s.Meta["change_periods"] != nil:
+			window = s.Meta["change_periods"].(int)
+		case s.Meta["consecutive_periods"] != nil:
+			window = s.Meta["consecutive_periods"].(int)
+		}
+		driver, shares := changeDriver(agg.OpenInterest, window)
+		signals = append(signals, withDriver(s, driver, shares))
+	}
+
+	for i := range signals {
+		signals[i].Exchange = AggregateExchange
+		signals[i].Severity = cfg.Severity.For(signals[i].Score)
+		signals[i].Meta["venues"] = agg.Venues
+	}
+	return data, signals
+}
+
+// AggregateResults 将多个交易所上同一币种的成功结果聚合并分析
+// 只有在至少两个不同交易所上都有数据的币种才会被聚合, 同一交易所的多个交易对只取
+// 排在前面的一个 (见 distinctVenues)。结果按币种首次出现的顺序返回。
+func AggregateResults(results []SymbolResult) []SymbolResult {
+	var assets []string
+	byAsset := map[string][]MarketData{}
+	for _, r := range results {
+		if r.Err != nil || r.Exchange == AggregateExchange {
+			continue
+		}
+		asset := BaseAsset(r.Symbol)
+		if _, ok := byAsset[asset]; !ok {
+			assets = append(assets, asset)
+		}
+		byAsset[asset] = append(byAsset[asset], r.Data)
+	}
+
+	var aggregated []SymbolResult
+	for _, asset := range assets {
+		venues := distinctVenues(byAsset[asset])
+		if len(venues) < 2 {
+			continue
+		}
+		data, signals := AnalyzeAggregate(BuildAggregate(asset, venues))
+		aggregated = append(aggregated, SymbolResult{
+			Symbol:   asset,
+			Exchange: AggregateExchange,
+			Data:     data,
+			Signals:  signals,
+		})
+	}
+	return aggregated
+}
+
+// changeDriver 计算最近 window 个周期内各交易所对总变化的贡献占比, 并返回贡献最大的交易所
+func changeDriver(points []AggregatePoint, window int) (string, map[string]float64) {
+	if len(points) <= window {
+		return "", nil
+	}
+	last, base := points[len(points)-1], points[len(points)-1-window]
+	deltas := map[string]float64{}
+	for venue, v := range last.ByVenue {
+		deltas[venue] = v - base.ByVenue[venue]
+	}
+	return dominantVenue(deltas, last.Total-base.Total)
+}
+
+// volumeDriver 计算各交易所最新成交额相对自身均值的超额部分占比, 并返回贡献最大的交易所
+func volumeDriver(points []AggregatePoint) (string, map[string]float64) {
+	if len(points) == 0 {
+		return "", nil
+	}
+	last := points[len(points)-1]
+	excess := map[string]float64{}
+	total := 0.0
+	for venue, v := range last.ByVenue {
+		history := make([]float64, len(points))
+		for i, p := range points {
+			history[i] = p.ByVenue[venue]
+		}
+		excess[venue] = v - CalculateMean(history)
+		total += excess[venue]
+	}
+	return dominantVenue(excess, total)
+}
+
+// dominantVenue 返回与总变化同向且幅度最大的交易所, 以及各交易所的贡献占比
+func dominantVenue(deltas map[string]float64, total float64) (string, map[string]float64) {
+	if total == 0 {
+		return "", nil
+	}
+	venues := make([]string, 0, len(deltas))
+	for venue := range deltas {
+		venues = append(venues, venue)
+	}
+	sort.Strings(venues) // 保证结果确定
+
+	driver, best := "", 0.0
+	shares := make(map[string]float64, len(deltas))
+	for _, venue := range venues {
+		share := deltas[venue] / total
+		shares[venue] = share
+		if share > best {
+			driver, best = venue, share
+		}
+	}
+	return driver, shares
+}
+
+// withDriver 将主导交易所信息写入信号描述与元数据
+func withDriver(signal models.Signal, driver string, shares map[string]float64) models.Signal {
+	if signal.Meta == nil {
+		signal.Meta = map[string]interface{}{}
+	}
+	if driver == "" {
+		signal.Description += "; 各交易所变化方向不一"
+		return signal
+	}
+	signal.Meta["driver_venue"] = driver
+	signal.Meta["venue_shares"] = shares
+	signal.Description += fmt.Sprintf("; 主导交易所: %s (贡献 %.0f%%)", driver, math.Round(shares[driver]*100))
+	if shares[driver] < 0.6 {
+		signal.Description += ", 属于全市场同步变化"
+	}
+	return signal
+}
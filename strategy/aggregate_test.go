@@ -0,0 +1,77 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"strconv"
+	"testing"
+)
+
+// venueData 构造某交易所上一个交易对的 MarketData, 每个周期的持仓价值与成交额固定
+func venueData(exchange, symbol string, oiValue, volume float64, n int) MarketData {
+	data := MarketData{Symbol: symbol, Exchange: exchange}
+	for i := 0; i < n; i++ {
+		ts := int64(i) * 900000
+		data.Klines = append(data.Klines, models.KlineData{Symbol: symbol, Timestamp: ts, Close: 1, Volume: volume, QuoteVolume: volume})
+		data.OIs = append(data.OIs, models.BinanceOI{Symbol: symbol, SumOpenInterestValue: strconv.FormatFloat(oiValue, 'f', -1, 64), Timestamp: ts})
+	}
+	return data
+}
+
+func TestAggregateResultsDistinctExchanges(t *testing.T) {
+	results := []SymbolResult{
+		{Symbol: "BTCUSDT", Exchange: "binance", Data: venueData("binance", "BTCUSDT", 100, 10, 5)},
+		{Symbol: "BTCUSDC", Exchange: "binance", Data: venueData("binance", "BTCUSDC", 50, 5, 5)},
+		{Symbol: "ETHUSDT", Exchange: "binance", Data: venueData("binance", "ETHUSDT", 100, 10, 5)},
+		{Symbol: "ETHUSDT", Exchange: "okx", Data: venueData("okx", "ETHUSDT", 30, 3, 5)},
+		{Symbol: "ETHUSDC", Exchange: "okx", Data: venueData("okx", "ETHUSDC", 20, 2, 5)},
+	}
+
+	aggregated := AggregateResults(results)
+	// BTC 只在一个交易所上有数据, 不应聚合
+	if len(aggregated) != 1 || aggregated[0].Symbol != "ETH" {
+		t.Fatalf("aggregated %d assets, want only ETH", len(aggregated))
+	}
+
+	agg := BuildAggregate("ETH", []MarketData{results[2].Data, results[3].Data, results[4].Data})
+	if len(agg.Venues) != 2 || agg.Venues[0] != "binance" || agg.Venues[1] != "okx" {
+		t.Fatalf("Venues = %v, want [binance okx]", agg.Venues)
+	}
+	last := agg.OpenInterest[len(agg.OpenInterest)-1]
+	if last.Total != 130 || last.ByVenue["okx"] != 30 {
+		t.Errorf("open interest = %+v, want the first okx symbol only", last)
+	}
+}
+
+func TestAnalyzeAggregateConsecutiveDriverWindow(t *testing.T) {
+	// 第 1 根之后 OI 连续上涨 8 个周期: 前 4 个周期主要由 binance 贡献, 后 4 个周期主要由 okx 贡献
+	binance := []float64{1000, 1000, 1020, 1040, 1060, 1080, 1081, 1082, 1083, 1084}
+	okx := []float64{1000, 1000, 1001, 1002, 1003, 1004, 1014, 1024, 1034, 1044}
+	venue := func(exchange string, ois []float64) MarketData {
+		data := MarketData{Symbol: "BTCUSDT", Exchange: exchange}
+		for i, oi := range ois {
+			ts := int64(i) * 900000
+			data.Klines = append(data.Klines, models.KlineData{Symbol: "BTCUSDT", Timestamp: ts, Close: 1, Volume: 1, QuoteVolume: 1})
+			data.OIs = append(data.OIs, models.BinanceOI{Symbol: "BTCUSDT", SumOpenInterestValue: strconv.FormatFloat(oi, 'f', -1, 64), Timestamp: ts})
+		}
+		return data
+	}
+
+	_, signals := AnalyzeAggregate(BuildAggregate("BTC", []MarketData{venue("binance", binance), venue("okx", okx)}))
+	found := false
+	for _, s := range signals {
+		if s.Meta["consecutive_periods"] == nil {
+			continue
+		}
+		found = true
+		if run := s.Meta["consecutive_periods"].(int); run != 8 {
+			t.Fatalf("consecutive_periods = %d, want 8", run)
+		}
+		// 主导交易所按整个连续区间计算, 而不是配置的最少周期数
+		if s.Meta["driver_venue"] != "binance" {
+			t.Errorf("driver_venue = %v, want binance over the whole run", s.Meta["driver_venue"])
+		}
+	}
+	if !found {
+		t.Fatal("no consecutive open interest signal")
+	}
+}
@@ -0,0 +1,201 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"fmt"
+	"time"
+)
+
+// DefaultMaxFillPeriods 是 AlignOptions.MaxFillPeriods 的默认值
+const DefaultMaxFillPeriods = 4
+
+// AlignOptions 控制 AlignMarketData 的对齐方式
+type AlignOptions struct {
+	// Now 返回判断K线是否收盘所用的当前时间, 回放历史数据时为录制时刻
+	Now func() time.Time
+	// MaxFillPeriods 是允许向前填充的最长连续缺失周期数
+	// 缺口更长时, 缺口之前的数据视为过期并被丢弃, 只保留最近一段连续数据。
+	MaxFillPeriods int
+}
+
+// DefaultAlignOptions 返回以当前时间判断收盘、最多填充 DefaultMaxFillPeriods 个周期的选项
+func DefaultAlignOptions() AlignOptions {
+	return AlignOptions{Now: time.Now, MaxFillPeriods: DefaultMaxFillPeriods}
+}
+
+// SplitClosedKlines 将K线分为已收盘K线与最后一根仍在形成中的K线 (没有时为 nil)
+// 收盘时间未知的K线按开盘时间加一个周期推算。
+func SplitClosedKlines(klines []models.KlineData, period time.Duration, now time.Time) ([]models.KlineData, *models.KlineData) {
+	nowMs := now.UnixMilli()
+	for i, k := range klines {
+		closeTime := k.CloseTime
+		if closeTime == 0 {
+			closeTime = k.Timestamp + period.Milliseconds() - 1
+		}
+		if closeTime >= nowMs {
+			live := k
+			return klines[:i], &live
+		}
+	}
+	return klines, nil
+}
+
+// FillKlineGaps 补齐已收盘K线中缺失的周期, 使K线构成连续的时间网格
+// 不超过 maxFill 的缺口以前一根收盘价的零成交量K线填充, 并标记为 Synthetic;
+// 更长的缺口之前的K线被丢弃。返回补齐后的K线与填充的周期数。
+func FillKlineGaps(klines []models.KlineData, period time.Duration, maxFill int) ([]models.KlineData, int, error) {
+	step := period.Milliseconds()
+	if step <= 0 {
+		return nil, 0, fmt.Errorf("invalid period %s", period)
+	}
+
+	var filled []models.KlineData
+	gaps := 0
+	for _, k := range klines {
+		if n := len(filled); n > 0 {
+			prev := filled[n-1]
+			if k.Timestamp <= prev.Timestamp {
+				continue // 重复或乱序的K线
+			}
+			missing := int((k.Timestamp-prev.Timestamp)/step) - 1
+			if missing > maxFill {
+				filled, gaps = nil, 0
+			} else {
+				for j := 1; j <= missing; j++ {
+					ts := prev.Timestamp + int64(j)*step
+					filled = append(filled, models.KlineData{
+						Symbol:    prev.Symbol,
+						Timestamp: ts,
+						CloseTime: ts + step - 1,
+						Open:      prev.Close,
+						High:      prev.Close,
+						Low:       prev.Close,
+						Close:     prev.Close,
+						Synthetic: true,
+					})
+				}
+				if missing > 0 {
+					gaps += missing
+				}
+			}
+		}
+		filled = append(filled, k)
+	}
+	return filled, gaps, nil
+}
+
+// alignSeries 将统计序列对齐到K线开盘时间网格 grid
+//
+// 统计接口的时间戳是快照时刻, 即所描述周期的结束时间, 因此时间戳为 T 的数据点
+// 归属于开盘时间为 T - period 的K线, 并改写为该开盘时间。网格之外 (例如属于
+// 未收盘K线) 的数据点被丢弃; 网格内缺失的周期按前值填充, 规则与 FillKlineGaps
+// 相同。序列首尾缺失的网格点都不做填充, 因此对齐后的序列可能比网格短, 并以序列
+// 自身最后一个真实数据点结束; 末尾缺失超过 maxFill 个周期说明序列已停止更新,
+// 此时返回空序列。
+//
+// 周期边界以网格起点为准而非 Unix 纪元, 因为周线按周一开盘, 与纪元 (周四) 不对齐。
+func alignSeries[T any](points []T, timestamp func(*T) *int64, grid []int64, period time.Duration, maxFill int) ([]T, int) {
+	if len(grid) == 0 {
+		return nil, 0
+	}
+	step := period.Milliseconds()
+	offset := grid[0] % step
+	byBar := make(map[int64]T, len(points))
+	for _, p := range points {
+		ts := *timestamp(&p)
+		bar := ts - ((ts-offset)%step+step)%step - step
+		byBar[bar] = p
+	}
+
+	var aligned []T
+	var prev T
+	started := false
+	gaps, missing := 0, 0
+	for _, bar := range grid {
+		p, ok := byBar[bar]
+		if !ok {
+			if started {
+				missing++
+			}
+			continue
+		}
+		if missing > maxFill {
+			aligned, gaps = nil, 0
+		} else {
+			for j := missing; j > 0; j-- {
+				fill := prev
+				*timestamp(&fill) = bar - int64(j)*step
+				aligned = append(aligned, fill)
+			}
+			gaps += missing
+		}
+		missing = 0
+		*timestamp(&p) = bar
+		aligned = append(aligned, p)
+		prev, started = p, true
+	}
+
+	// 序列末尾缺失不做填充, 否则旧值会冒充最新周期的数据; 长缺口说明序列已停止更新
+	if missing > maxFill {
+		return nil, 0
+	}
+	return aligned, gaps
+}
+
+// AlignMarketData 使 MarketData 中的所有序列只包含已收盘周期并对齐到同一时间网格
+//
+// 最后一根未收盘K线被移入 LiveKline; K线缺口按 FillKlineGaps 处理; 持仓量、
+// 多空比、主动买卖比与大户多空比按 alignSeries 对齐到K线开盘时间。所有序列最多
+// 保留最近 limit 个周期。填充或丢弃的数据记录在 Warnings 中。
+func AlignMarketData(data *MarketData, period time.Duration, limit int, opts AlignOptions) error {
+	closed, live := SplitClosedKlines(data.Klines, period, opts.Now())
+	data.LiveKline = live
+
+	klines, gaps, err := FillKlineGaps(closed, period, opts.MaxFillPeriods)
+	if err != nil {
+		return err
+	}
+	if len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	data.Klines = klines
+	if gaps > 0 {
+		data.Warnings = append(data.Warnings, fmt.Sprintf("klines: filled %d missing periods", gaps))
+	}
+
+	grid := make([]int64, len(klines))
+	for i, k := range klines {
+		grid[i] = k.Timestamp
+	}
+	report := func(name string, before, after, gaps int) {
+		switch {
+		case before > 0 && after == 0:
+			data.Warnings = append(data.Warnings, fmt.Sprintf("%s: no data on the closed-candle grid, series dropped", name))
+		case gaps > 0:
+			data.Warnings = append(data.Warnings, fmt.Sprintf("%s: filled %d missing periods", name, gaps))
+		}
+	}
+
+	var n int
+	before := len(data.OIs)
+	data.OIs, n = alignSeries(data.OIs, func(p *models.BinanceOI) *int64 { return &p.Timestamp }, grid, period, opts.MaxFillPeriods)
+	report("open interest", before, len(data.OIs), n)
+
+	before = len(data.LSRatios)
+	data.LSRatios, n = alignSeries(data.LSRatios, func(p *models.GlobalLongShortRatio) *int64 { return &p.Timestamp }, grid, period, opts.MaxFillPeriods)
+	report("long/short ratio", before, len(data.LSRatios), n)
+
+	before = len(data.TakerRatios)
+	data.TakerRatios, n = alignSeries(data.TakerRatios, func(p *models.TakerLongShortRatio) *int64 { return &p.Timestamp }, grid, period, opts.MaxFillPeriods)
+	report("taker buy/sell ratio", before, len(data.TakerRatios), n)
+
+	before = len(data.TopAccountRatios)
+	data.TopAccountRatios, n = alignSeries(data.TopAccountRatios, func(p *models.TopLongShortRatio) *int64 { return &p.Timestamp }, grid, period, opts.MaxFillPeriods)
+	report("top trader account ratio", before, len(data.TopAccountRatios), n)
+
+	before = len(data.TopPositionRatios)
+	data.TopPositionRatios, n = alignSeries(data.TopPositionRatios, func(p *models.TopLongShortRatio) *int64 { return &p.Timestamp }, grid, period, opts.MaxFillPeriods)
+	report("top trader position ratio", before, len(data.TopPositionRatios), n)
+
+	return nil
+}
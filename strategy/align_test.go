@@ -0,0 +1,164 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testStep = int64(15 * time.Minute / time.Millisecond)
+
+// barsAt 构造开盘时间为 ts*testStep 的K线, 成交量均为 100
+func barsAt(ts ...int64) []models.KlineData {
+	out := make([]models.KlineData, len(ts))
+	for i, t := range ts {
+		out[i] = models.KlineData{Timestamp: t * testStep, Close: float64(t), Volume: 100}
+	}
+	return out
+}
+
+// oisForBars 构造描述给定K线的持仓量快照, 快照时间为K线收盘时刻
+func oisForBars(bars ...int64) []models.BinanceOI {
+	out := make([]models.BinanceOI, len(bars))
+	for i, b := range bars {
+		out[i] = models.BinanceOI{SumOpenInterest: strconv.FormatInt(b, 10), Timestamp: (b + 1) * testStep}
+	}
+	return out
+}
+
+func TestFillKlineGaps(t *testing.T) {
+	tests := []struct {
+		name      string
+		bars      []int64
+		want      []int64 // 补齐后的开盘时间 (周期序号)
+		synthetic []int64
+		gaps      int
+	}{
+		{"contiguous", []int64{0, 1, 2}, []int64{0, 1, 2}, nil, 0},
+		{"short gap filled", []int64{0, 1, 4}, []int64{0, 1, 2, 3, 4}, []int64{2, 3}, 2},
+		{"long gap drops older klines", []int64{0, 1, 7}, []int64{7}, nil, 0},
+		{"duplicate skipped", []int64{0, 1, 1, 2}, []int64{0, 1, 2}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gaps, err := FillKlineGaps(barsAt(tt.bars...), 15*time.Minute, 4)
+			if err != nil {
+				t.Fatalf("FillKlineGaps: %v", err)
+			}
+			if gaps != tt.gaps {
+				t.Errorf("gaps = %d, want %d", gaps, tt.gaps)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d klines, want %d", len(got), len(tt.want))
+			}
+			synthetic := map[int64]bool{}
+			for _, b := range tt.synthetic {
+				synthetic[b] = true
+			}
+			for i, k := range got {
+				if k.Timestamp != tt.want[i]*testStep {
+					t.Errorf("kline %d opens at %d, want %d", i, k.Timestamp, tt.want[i]*testStep)
+				}
+				if k.Synthetic != synthetic[tt.want[i]] {
+					t.Errorf("kline %d: Synthetic = %v", i, k.Synthetic)
+				}
+			}
+		})
+	}
+}
+
+func TestAlignSeriesTail(t *testing.T) {
+	grid := []int64{1, 2, 3, 4, 5, 6, 7, 8}
+	for i := range grid {
+		grid[i] *= testStep
+	}
+
+	tests := []struct {
+		name string
+		bars []int64
+		want []int64 // 对齐后各点的数值 (即所描述K线的周期序号), nil 表示序列被丢弃
+		gaps int
+	}{
+		{"complete", []int64{1, 2, 3, 4, 5, 6, 7, 8}, []int64{1, 2, 3, 4, 5, 6, 7, 8}, 0},
+		// 末尾缺失不填充, 序列以最后一个真实点结束
+		{"tail missing", []int64{1, 2, 3, 4, 5, 6}, []int64{1, 2, 3, 4, 5, 6}, 0},
+		{"stale", []int64{1, 2, 3}, nil, 0},
+		{"inner gap filled", []int64{1, 2, 5, 6, 7, 8}, []int64{1, 2, 2, 2, 5, 6, 7, 8}, 2},
+		// 属于未收盘K线的点落在网格之外
+		{"live bar dropped", []int64{6, 7, 8, 9}, []int64{6, 7, 8}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gaps := alignSeries(oisForBars(tt.bars...), func(p *models.BinanceOI) *int64 { return &p.Timestamp }, grid, 15*time.Minute, 4)
+			if gaps != tt.gaps {
+				t.Errorf("gaps = %d, want %d", gaps, tt.gaps)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d points, want %d", len(got), len(tt.want))
+			}
+			// 对齐后的点落在连续的网格点上, 第一个点为序列的第一个真实点
+			for i, p := range got {
+				if p.SumOpenInterest != strconv.FormatInt(tt.want[i], 10) {
+					t.Errorf("point %d = %s, want %d", i, p.SumOpenInterest, tt.want[i])
+				}
+				if want := (tt.want[0] + int64(i)) * testStep; p.Timestamp != want {
+					t.Errorf("point %d at %d, want %d", i, p.Timestamp, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAlignSeriesWeekly(t *testing.T) {
+	// 币安周线在周一 00:00 UTC 开盘, 与 Unix 纪元 (周四) 不对齐
+	week := 7 * 24 * time.Hour
+	monday := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	step := week.Milliseconds()
+	grid := []int64{monday, monday + step, monday + 2*step}
+
+	points := []models.BinanceOI{
+		{SumOpenInterest: "1", Timestamp: monday + step},
+		{SumOpenInterest: "2", Timestamp: monday + 2*step + 5000}, // 快照略晚于收盘
+		{SumOpenInterest: "3", Timestamp: monday + 3*step},
+	}
+	got, _ := alignSeries(points, func(p *models.BinanceOI) *int64 { return &p.Timestamp }, grid, week, 4)
+	if len(got) != len(grid) {
+		t.Fatalf("got %d points, want %d", len(got), len(grid))
+	}
+	for i, p := range got {
+		if p.Timestamp != grid[i] || p.SumOpenInterest != strconv.Itoa(i+1) {
+			t.Errorf("point %d = %s at %d, want %d at %d", i, p.SumOpenInterest, p.Timestamp, i+1, grid[i])
+		}
+	}
+}
+
+func TestDetectVolumeSignalSkipsSynthetic(t *testing.T) {
+	cfg := DefaultConfig().Detectors.Volume
+	cfg.Estimator = EstimatorZScore
+
+	var calm []models.KlineData
+	for i := 0; i < 20; i++ {
+		calm = append(calm, models.KlineData{Timestamp: int64(i) * testStep, Volume: float64(99 + 2*(i%2))})
+	}
+	spike := models.KlineData{Timestamp: 24 * testStep, Volume: 110, Close: 1}
+	gap := func(from int) []models.KlineData {
+		var out []models.KlineData
+		for i := from; i < from+4; i++ {
+			out = append(out, models.KlineData{Timestamp: int64(i) * testStep, Synthetic: true})
+		}
+		return out
+	}
+
+	// 补齐的零成交量K线若参与统计会抬高标准差, 掩盖最新一根的放量
+	klines := append(append(append([]models.KlineData{}, calm...), gap(20)...), spike)
+	if DetectVolumeSignal(klines, cfg) == nil {
+		t.Error("got no signal with synthetic bars in the window, want the spike detected")
+	}
+
+	// 最新K线本身是补齐的, 不应产生信号
+	klines = append(append(append([]models.KlineData{}, calm...), spike), gap(25)...)
+	if s := DetectVolumeSignal(klines, cfg); s != nil {
+		t.Errorf("got signal %q on a synthetic bar, want none", s.Description)
+	}
+}
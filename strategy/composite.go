@@ -0,0 +1,97 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DetectComposite 将同一交易对在本次分析中同时触发的信号合并为一个复合信号
+//
+// 每个检测器 (按 Meta["detector"] 区分, 同一检测器的多个信号只计一次) 贡献其
+// 权重, 权重为 0 或未配置的检测器不参与。参与的检测器不少于 cfg.MinComponents
+// 且加权评分不低于 cfg.MinScore 时, 在返回的信号末尾追加复合信号; cfg.Suppress
+// 为 true 时同时移除列入 components 的组成信号, 同一检测器未列入的其余信号保留。
+// 未触发时原样返回 signals。
+//
+// 复合信号的方向为各组成信号方向按权重投票的结果, Score 为加权评分相对
+// cfg.MinScore 的倍数, Severity 取组成信号中最高者且至少为 warn。
+func DetectComposite(symbol string, signals []models.Signal, cfg CompositeConfig) []models.Signal {
+	if !cfg.Enabled {
+		return signals
+	}
+
+	var components []models.CompositeComponent
+	seen := map[string]bool{}
+	represented := map[int]bool{} // 列入 components 的信号下标
+	score, lean := 0.0, 0.0
+	severity := models.SeverityWarn // 多信号共振至少为 warn
+	var latest models.Signal
+	for i, s := range signals {
+		detector, _ := s.Meta["detector"].(string)
+		weight := cfg.Weights[detector]
+		if weight <= 0 || seen[detector] {
+			continue
+		}
+		seen[detector] = true
+		represented[i] = true
+		score += weight
+		components = append(components, models.CompositeComponent{
+			Detector:    detector,
+			SignalType:  s.SignalType,
+			Direction:   s.Direction,
+			Severity:    s.Severity,
+			Weight:      weight,
+			Description: s.Description,
+		})
+		switch s.Direction {
+		case models.Bullish:
+			lean += weight
+		case models.Bearish:
+			lean -= weight
+		}
+		if s.Severity.Rank() > severity.Rank() {
+			severity = s.Severity
+		}
+		if s.Timestamp.After(latest.Timestamp) {
+			latest = s
+		}
+	}
+	if len(components) < cfg.MinComponents || score < cfg.MinScore {
+		return signals
+	}
+
+	// 按权重从高到低说明, 权重相同时保持检测顺序
+	sort.SliceStable(components, func(i, j int) bool { return components[i].Weight > components[j].Weight })
+	parts := make([]string, len(components))
+	for i, c := range components {
+		parts[i] = fmt.Sprintf("%s (%.1f)", c.SignalType, c.Weight)
+	}
+	composite := models.Signal{
+		Symbol:      symbol,
+		SignalType:  models.CompositeSignal,
+		Timestamp:   latest.Timestamp,
+		Description: fmt.Sprintf("%d 个信号共振: %s, 综合评分 %.1f (阈值: %.1f)", len(components), strings.Join(parts, " + "), score, cfg.MinScore),
+		Direction:   directionOf(lean),
+		Severity:    severity,
+		Score:       score / cfg.MinScore,
+		Meta: map[string]interface{}{
+			"detector":   "composite",
+			"score":      score,
+			"threshold":  cfg.MinScore,
+			"components": components,
+			"suppressed": cfg.Suppress,
+			"params":     cfg,
+		},
+	}
+
+	var out []models.Signal
+	for i, s := range signals {
+		if cfg.Suppress && represented[i] {
+			continue
+		}
+		out = append(out, s)
+	}
+	return append(out, composite)
+}
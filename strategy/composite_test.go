@@ -0,0 +1,48 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"testing"
+)
+
+func TestDetectCompositeSuppressesRepresentedSignals(t *testing.T) {
+	cfg := CompositeConfig{
+		Enabled:       true,
+		Weights:       map[string]float64{"volume": 1, "open_interest": 1},
+		MinComponents: 2,
+		MinScore:      2,
+		Suppress:      true,
+	}
+	signal := func(detector, description string) models.Signal {
+		return models.Signal{Description: description, Severity: models.SeverityInfo, Meta: map[string]interface{}{"detector": detector}}
+	}
+	// open_interest 触发了两个信号, 只有第一个列入 components
+	signals := []models.Signal{
+		signal("open_interest", "oi window"),
+		signal("open_interest", "oi single period"),
+		signal("volume", "volume"),
+		signal("funding", "funding"), // 未配置权重, 不参与
+	}
+
+	out := DetectComposite("BTCUSDT", signals, cfg)
+	if len(out) == 0 || out[len(out)-1].SignalType != models.CompositeSignal {
+		t.Fatalf("no composite signal in %v", out)
+	}
+	components := out[len(out)-1].Meta["components"].([]models.CompositeComponent)
+	listed := map[string]bool{}
+	for _, c := range components {
+		listed[c.Description] = true
+	}
+
+	var kept []string
+	for _, s := range out[:len(out)-1] {
+		if listed[s.Description] {
+			t.Errorf("signal %q is listed in components but was not suppressed", s.Description)
+		}
+		kept = append(kept, s.Description)
+	}
+	want := []string{"oi single period", "funding"}
+	if len(kept) != len(want) || kept[0] != want[0] || kept[1] != want[1] {
+		t.Errorf("kept %v, want %v", kept, want)
+	}
+}
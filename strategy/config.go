@@ -0,0 +1,453 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// VolumeConfig 是成交量异常检测器的参数
+type VolumeConfig struct {
+	ZScoreThreshold float64 `json:"z_score_threshold"`
+	// Window 是参与计算的最近周期数, 0 表示使用全部已获取的周期
+	Window int `json:"window"`
+	// LogScale 为 true 时先对成交量取 log(1+v) 再计算分数, 压缩右偏分布
+	LogScale bool `json:"log_scale"`
+	StatsConfig
+}
+
+// OpenInterestConfig 是持仓量异动检测器的参数
+type OpenInterestConfig struct {
+	// ChangePercent 是最近 ChangeWindow 个周期内的持仓量变化阈值 (%)
+	ChangePercent float64 `json:"change_percent"`
+	// ChangeWindow 是比较的周期数, 即与 ChangeWindow 个周期前的持仓量比较;
+	// 0 表示跨越全部已获取的周期 (lookback-1 个), 默认 lookback 96 在 15m 周期下约为 24 小时
+	ChangeWindow int `json:"change_window"`
+	// ConsecutivePeriods 是判定连续上涨/下跌的周期数
+	ConsecutivePeriods int `json:"consecutive_periods"`
+	// SinglePeriodPercent 是单周期变化阈值 (%)
+	SinglePeriodPercent float64 `json:"single_period_percent"`
+}
+
+// LSRatioConfig 是多空比极端检测器的参数
+type LSRatioConfig struct {
+	ZScoreThreshold float64 `json:"z_score_threshold"`
+	Window          int     `json:"window"`
+	StatsConfig
+}
+
+// TakerImbalanceConfig 是主动买卖失衡检测器的参数
+type TakerImbalanceConfig struct {
+	ZScoreThreshold float64 `json:"z_score_threshold"`
+	Window          int     `json:"window"`
+	StatsConfig
+}
+
+// SmartMoneyConfig 是大户与散户多空背离检测器的参数
+type SmartMoneyConfig struct {
+	// Margin 是双方多头占比偏离 50% 的最小幅度, 例如 0.05 表示 55%/45%
+	Margin float64 `json:"margin"`
+}
+
+// FundingConfig 是资金费率异常检测器的参数
+type FundingConfig struct {
+	// ExtremeThreshold 是单期费率的极端阈值, 0.0005 即 0.05%
+	ExtremeThreshold float64 `json:"extreme_threshold"`
+	// FlipMinChange 是费率翻转时的最小变化幅度
+	FlipMinChange float64 `json:"flip_min_change"`
+}
+
+// LiquidationConfig 是强平瀑布检测器的参数
+type LiquidationConfig struct {
+	// BaselineMultiplier 是强平额需达到基线 (此前周期均值) 的倍数
+	BaselineMultiplier float64 `json:"baseline_multiplier"`
+	// MinNotional 是最小强平名义价值 (USDT), 过滤低流动性噪音
+	MinNotional float64 `json:"min_notional"`
+	// MinPriceMove 是不利价格变动的最小幅度 (%)
+	MinPriceMove float64 `json:"min_price_move"`
+}
+
+// OrderBookConfig 是盘口失衡与挂单墙检测器的参数
+type OrderBookConfig struct {
+	// ImbalanceRange 是计算失衡度所用的范围 (±%), 必须是 DepthRanges 之一
+	ImbalanceRange float64 `json:"imbalance_range"`
+	// ImbalanceThreshold 是 (买-卖)/(买+卖) 的阈值, 0.6 即 4:1
+	ImbalanceThreshold float64 `json:"imbalance_threshold"`
+	// WallRange 是搜索挂单墙的范围 (±%)
+	WallRange float64 `json:"wall_range"`
+	// WallMultiplier 是挂单墙需达到范围内单档名义价值中位数的倍数
+	WallMultiplier float64 `json:"wall_multiplier"`
+	// MinWallLevels 是计算中位数所需的最少档位数
+	MinWallLevels int `json:"min_wall_levels"`
+}
+
+// BasisConfig 是基差异常检测器的参数
+type BasisConfig struct {
+	ZScoreThreshold float64 `json:"z_score_threshold"`
+	// MinAnnualized 是异常扩张时年化基差绝对值的下限, 0.1 即 10%
+	MinAnnualized float64 `json:"min_annualized"`
+	// MinInversion 是倒挂时年化基差绝对值的下限
+	MinInversion float64 `json:"min_inversion"`
+	// MinPerpPremium 是永续合约未年化溢价绝对值的下限, 0.0005 即 0.05%。
+	// 永续合约溢价按资金费率周期年化后会被放大约千倍, 年化下限对其形同虚设,
+	// 因此永续合约的两种模式都改用此下限
+	MinPerpPremium float64 `json:"min_perp_premium"`
+	StatsConfig
+}
+
+// SpotPerpVolumeConfig 是期现成交额比异常检测器的参数
+type SpotPerpVolumeConfig struct {
+	ZScoreThreshold float64 `json:"z_score_threshold"`
+	// MinDeviation 是最新比值偏离历史中位数的最小倍数
+	MinDeviation float64 `json:"min_deviation"`
+	MinPeriods   int     `json:"min_periods"`
+	StatsConfig
+}
+
+// StatsConfig 是基于 Z-Score 的检测器计算异常分数的方式, 嵌入在各检测器的参数中
+// 默认 (zscore, 基线包含最后一个点) 与原先的均值/标准差 Z-Score 一致。
+// 成交量等右偏序列建议使用 mad 并设置 exclude_last, 避免尖峰抬高自身的基线。
+type StatsConfig struct {
+	// Estimator 是 zscore, mad, winsorized 或 trimmed, 见 Estimator
+	Estimator Estimator `json:"estimator"`
+	// ExcludeLast 为 true 时基线不包含被检测的最后一个数据点
+	ExcludeLast bool `json:"exclude_last"`
+	// TrimFraction 是 winsorized 与 trimmed 在每侧处理的比例
+	TrimFraction float64 `json:"trim_fraction"`
+}
+
+// defaultStats 是各检测器默认的统计方式
+var defaultStats = StatsConfig{Estimator: EstimatorZScore, TrimFraction: 0.1}
+
+// validate 校验统计参数, prefix 为所属检测器的名称
+func (c StatsConfig) validate(prefix string) error {
+	switch c.Estimator {
+	case EstimatorZScore, EstimatorMAD, EstimatorWinsorized, EstimatorTrimmed:
+	default:
+		return fmt.Errorf("%s.estimator: unknown estimator %q (want zscore, mad, winsorized or trimmed)", prefix, c.Estimator)
+	}
+	if c.TrimFraction < 0 || c.TrimFraction >= 0.5 {
+		return fmt.Errorf("%s.trim_fraction must be within [0, 0.5)", prefix)
+	}
+	return nil
+}
+
+// Label 返回分数在描述中的名称
+func (c StatsConfig) Label() string {
+	switch c.Estimator {
+	case EstimatorMAD:
+		return "MAD Z-Score"
+	case EstimatorWinsorized:
+		return "缩尾 Z-Score"
+	case EstimatorTrimmed:
+		return "截尾 Z-Score"
+	}
+	return "Z-Score"
+}
+
+// CompositeConfig 是复合信号 (多信号共振) 的参数, 见 DetectComposite
+type CompositeConfig struct {
+	Enabled bool `json:"enabled"`
+	// Weights 是各检测器 (按注册名) 的权重, 未列出的检测器不参与共振
+	Weights map[string]float64 `json:"weights"`
+	// MinComponents 是参与共振的最少检测器数
+	MinComponents int `json:"min_components"`
+	// MinScore 是触发复合信号的最低加权评分
+	MinScore float64 `json:"min_score"`
+	// Suppress 为 true 时, 触发复合信号后不再单独发送列入 components 的组成信号
+	Suppress bool `json:"suppress"`
+}
+
+// SeverityConfig 是按信号 Score 划分严重程度的阈值
+// Score 低于 Warn 为 info, 低于 Critical 为 warn, 否则为 critical。
+type SeverityConfig struct {
+	Warn     float64 `json:"warn"`
+	Critical float64 `json:"critical"`
+}
+
+// For 返回 score 对应的严重程度
+func (c SeverityConfig) For(score float64) models.Severity {
+	switch {
+	case score >= c.Critical:
+		return models.SeverityCritical
+	case score >= c.Warn:
+		return models.SeverityWarn
+	}
+	return models.SeverityInfo
+}
+
+// DetectorConfig 汇总所有内置检测器的参数
+type DetectorConfig struct {
+	Volume               VolumeConfig         `json:"volume"`
+	OpenInterest         OpenInterestConfig   `json:"open_interest"`
+	LSRatio              LSRatioConfig        `json:"long_short_ratio"`
+	TakerImbalance       TakerImbalanceConfig `json:"taker_imbalance"`
+	SmartMoneyDivergence SmartMoneyConfig     `json:"smart_money_divergence"`
+	Funding              FundingConfig        `json:"funding"`
+	LiquidationCascade   LiquidationConfig    `json:"liquidation_cascade"`
+	OrderBook            OrderBookConfig      `json:"orderbook"`
+	Basis                BasisConfig          `json:"basis"`
+	SpotPerpVolume       SpotPerpVolumeConfig `json:"spot_perp_volume"`
+	Composite            CompositeConfig      `json:"composite"`
+	Severity             SeverityConfig       `json:"severity"`
+}
+
+// Config 是监控配置: 数据周期、回溯长度、检测器参数及按交易对的覆盖
+//
+// 配置文件为 JSON, 未出现的字段保留默认值, 例如:
+//
+//	{
+//	  "interval": "15m",
+//	  "lookback": 96,
+//	  "detectors": {"volume": {"z_score_threshold": 2.5}},
+//	  "symbols": {"PEPEUSDT": {"volume": {"z_score_threshold": 3.5, "window": 48}}}
+//	}
+//
+// symbols 中的覆盖在全局检测器参数的基础上生效, 同样只需列出改动的字段。
+type Config struct {
+	Interval  string                     `json:"interval"`
+	Lookback  int                        `json:"lookback"`
+	Detectors DetectorConfig             `json:"detectors"`
+	Symbols   map[string]json.RawMessage `json:"symbols,omitempty"`
+
+	// symbols 是校验后按交易对 (大写) 解析的完整检测器参数
+	symbols map[string]DetectorConfig
+}
+
+// DefaultConfig 返回内置的默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Interval: "15m",
+		Lookback: 96,
+		Detectors: DetectorConfig{
+			Volume:               VolumeConfig{ZScoreThreshold: 2.0, StatsConfig: defaultStats},
+			OpenInterest:         OpenInterestConfig{ChangePercent: 10, ChangeWindow: 0, ConsecutivePeriods: 4, SinglePeriodPercent: 3.5},
+			LSRatio:              LSRatioConfig{ZScoreThreshold: 2.0, StatsConfig: defaultStats},
+			TakerImbalance:       TakerImbalanceConfig{ZScoreThreshold: 2.0, StatsConfig: defaultStats},
+			SmartMoneyDivergence: SmartMoneyConfig{Margin: 0.05},
+			Funding:              FundingConfig{ExtremeThreshold: 0.0005, FlipMinChange: 0.0003},
+			LiquidationCascade:   LiquidationConfig{BaselineMultiplier: 5, MinNotional: 100000, MinPriceMove: 0.5},
+			OrderBook:            OrderBookConfig{ImbalanceRange: 1, ImbalanceThreshold: 0.6, WallRange: 2, WallMultiplier: 10, MinWallLevels: 10},
+			Basis:                BasisConfig{ZScoreThreshold: 2.5, MinAnnualized: 0.1, MinInversion: 0.02, MinPerpPremium: 0.0005, StatsConfig: defaultStats},
+			SpotPerpVolume:       SpotPerpVolumeConfig{ZScoreThreshold: 2.5, MinDeviation: 2, MinPeriods: 20, StatsConfig: defaultStats},
+			Composite: CompositeConfig{
+				Enabled: true,
+				Weights: map[string]float64{
+					"volume":                 1.0,
+					"open_interest":          1.0,
+					"long_short_ratio":       0.8,
+					"taker_imbalance":        0.8,
+					"smart_money_divergence": 0.8,
+					"funding":                0.7,
+					"liquidation_cascade":    1.2,
+					"orderbook":              0.5,
+					"basis":                  0.6,
+					"spot_perp_volume":       0.6,
+				},
+				MinComponents: 2,
+				MinScore:      2.0,
+			},
+			Severity: SeverityConfig{Warn: 1.5, Critical: 2.5},
+		},
+	}
+}
+
+// ParseConfig 在默认配置的基础上解析 JSON 配置并校验
+// 未知字段视为错误, 以免拼写错误的参数被静默忽略。
+func ParseConfig(body []byte) (*Config, error) {
+	cfg := DefaultConfig()
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := decodeStrict(body, cfg); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadConfigFile 读取并解析 JSON 配置文件
+func LoadConfigFile(path string) (*Config, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	return ParseConfig(body)
+}
+
+// ConfigFromEnv 从环境变量构建配置: DETECTOR_CONFIG 为 JSON 配置 (格式同配置文件),
+// DATA_INTERVAL、LOOKBACK_PERIOD 与 SMART_MONEY_DIVERGENCE_MARGIN 覆盖其中对应的全局参数。
+func ConfigFromEnv(getenv func(string) string) (*Config, error) {
+	cfg := DefaultConfig()
+	if body := getenv("DETECTOR_CONFIG"); strings.TrimSpace(body) != "" {
+		if err := decodeStrict([]byte(body), cfg); err != nil {
+			return nil, fmt.Errorf("invalid DETECTOR_CONFIG: %w", err)
+		}
+	}
+	if v := getenv("DATA_INTERVAL"); v != "" {
+		cfg.Interval = v
+	}
+	if v := getenv("LOOKBACK_PERIOD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOOKBACK_PERIOD: %w", err)
+		}
+		cfg.Lookback = n
+	}
+	if v := getenv("SMART_MONEY_DIVERGENCE_MARGIN"); v != "" {
+		margin, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SMART_MONEY_DIVERGENCE_MARGIN: %w", err)
+		}
+		cfg.Detectors.SmartMoneyDivergence.Margin = margin
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func decodeStrict(body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Validate 校验配置并解析按交易对的覆盖, 返回第一个错误
+func (c *Config) Validate() error {
+	if _, err := ParseInterval(c.Interval); err != nil {
+		return fmt.Errorf("invalid config: interval: %w", err)
+	}
+	if c.Lookback < 2 {
+		return fmt.Errorf("invalid config: lookback must be at least 2, got %d", c.Lookback)
+	}
+	if err := c.Detectors.validate(c.Lookback); err != nil {
+		return fmt.Errorf("invalid config: detectors: %w", err)
+	}
+
+	c.symbols = make(map[string]DetectorConfig, len(c.Symbols))
+	for symbol, raw := range c.Symbols {
+		d := c.Detectors
+		// 权重表是引用类型, 覆盖前先复制, 避免改动全局参数
+		d.Composite.Weights = make(map[string]float64, len(c.Detectors.Composite.Weights))
+		for name, w := range c.Detectors.Composite.Weights {
+			d.Composite.Weights[name] = w
+		}
+		if err := decodeStrict(raw, &d); err != nil {
+			return fmt.Errorf("invalid config: symbols.%s: %w", symbol, err)
+		}
+		if err := d.validate(c.Lookback); err != nil {
+			return fmt.Errorf("invalid config: symbols.%s: %w", symbol, err)
+		}
+		c.symbols[strings.ToUpper(symbol)] = d
+	}
+	return nil
+}
+
+func (d DetectorConfig) validate(lookback int) error {
+	type check struct {
+		name string
+		ok   bool
+	}
+	window := func(w int) bool { return w == 0 || (w >= 2 && w <= lookback) }
+	depthRange := false
+	for _, r := range DepthRanges {
+		depthRange = depthRange || r == d.OrderBook.ImbalanceRange
+	}
+	checks := []check{
+		{"volume.z_score_threshold must be positive", d.Volume.ZScoreThreshold > 0},
+		{"volume.window must be 0 or within [2, lookback]", window(d.Volume.Window)},
+		{"open_interest.change_percent must be positive", d.OpenInterest.ChangePercent > 0},
+		{fmt.Sprintf("open_interest.change_window (%d) must be 0 or within [1, lookback-1] with lookback %d", d.OpenInterest.ChangeWindow, lookback),
+			d.OpenInterest.ChangeWindow >= 0 && d.OpenInterest.ChangeWindow < lookback},
+		{"open_interest.consecutive_periods must be within [2, lookback-1]", d.OpenInterest.ConsecutivePeriods >= 2 && d.OpenInterest.ConsecutivePeriods < lookback},
+		{"open_interest.single_period_percent must be positive", d.OpenInterest.SinglePeriodPercent > 0},
+		{"long_short_ratio.z_score_threshold must be positive", d.LSRatio.ZScoreThreshold > 0},
+		{"long_short_ratio.window must be 0 or within [2, lookback]", window(d.LSRatio.Window)},
+		{"taker_imbalance.z_score_threshold must be positive", d.TakerImbalance.ZScoreThreshold > 0},
+		{"taker_imbalance.window must be 0 or within [2, lookback]", window(d.TakerImbalance.Window)},
+		{"smart_money_divergence.margin must be within (0, 0.5)", d.SmartMoneyDivergence.Margin > 0 && d.SmartMoneyDivergence.Margin < 0.5},
+		{"funding.extreme_threshold must be positive", d.Funding.ExtremeThreshold > 0},
+		{"funding.flip_min_change must be positive", d.Funding.FlipMinChange > 0},
+		{"liquidation_cascade.baseline_multiplier must be positive", d.LiquidationCascade.BaselineMultiplier > 0},
+		{"liquidation_cascade.min_notional must not be negative", d.LiquidationCascade.MinNotional >= 0},
+		{"liquidation_cascade.min_price_move must not be negative", d.LiquidationCascade.MinPriceMove >= 0},
+		{fmt.Sprintf("orderbook.imbalance_range must be one of %v", DepthRanges), depthRange},
+		{"orderbook.imbalance_threshold must be within (0, 1)", d.OrderBook.ImbalanceThreshold > 0 && d.OrderBook.ImbalanceThreshold < 1},
+		{"orderbook.wall_range must be positive", d.OrderBook.WallRange > 0},
+		{"orderbook.wall_multiplier must be greater than 1", d.OrderBook.WallMultiplier > 1},
+		{"orderbook.min_wall_levels must be at least 2", d.OrderBook.MinWallLevels >= 2},
+		{"basis.z_score_threshold must be positive", d.Basis.ZScoreThreshold > 0},
+		{"basis.min_annualized must not be negative", d.Basis.MinAnnualized >= 0},
+		{"basis.min_inversion must not be negative", d.Basis.MinInversion >= 0},
+		{"basis.min_perp_premium must not be negative", d.Basis.MinPerpPremium >= 0},
+		{"spot_perp_volume.z_score_threshold must be positive", d.SpotPerpVolume.ZScoreThreshold > 0},
+		{"spot_perp_volume.min_deviation must be at least 1", d.SpotPerpVolume.MinDeviation >= 1},
+		{"spot_perp_volume.min_periods must be within [2, lookback]", d.SpotPerpVolume.MinPeriods >= 2 && d.SpotPerpVolume.MinPeriods <= lookback},
+		{"composite.min_components must be at least 2", d.Composite.MinComponents >= 2},
+		{"composite.min_score must be positive", d.Composite.MinScore > 0},
+		{"severity.warn must be positive", d.Severity.Warn > 0},
+		{"severity.critical must not be below severity.warn", d.Severity.Critical >= d.Severity.Warn},
+	}
+	for _, c := range checks {
+		if !c.ok {
+			return fmt.Errorf("%s", c.name)
+		}
+	}
+	stats := []struct {
+		name  string
+		stats StatsConfig
+	}{
+		{"volume", d.Volume.StatsConfig},
+		{"long_short_ratio", d.LSRatio.StatsConfig},
+		{"taker_imbalance", d.TakerImbalance.StatsConfig},
+		{"basis", d.Basis.StatsConfig},
+		{"spot_perp_volume", d.SpotPerpVolume.StatsConfig},
+	}
+	for _, s := range stats {
+		if err := s.stats.validate(s.name); err != nil {
+			return err
+		}
+	}
+	for name, w := range d.Composite.Weights {
+		if !DefaultRegistry.registered(name) {
+			return fmt.Errorf("composite.weights: unknown detector %q", name)
+		}
+		if w < 0 {
+			return fmt.Errorf("composite.weights.%s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+// For 返回 symbol 生效的检测器参数 (全局参数加上该交易对的覆盖)
+// 配置需已通过 Validate。
+func (c *Config) For(symbol string) DetectorConfig {
+	if d, ok := c.symbols[strings.ToUpper(symbol)]; ok {
+		return d
+	}
+	return c.Detectors
+}
+
+var activeConfig atomic.Pointer[Config]
+
+func init() {
+	activeConfig.Store(DefaultConfig())
+}
+
+// ActiveConfig 返回内置检测器当前使用的配置
+func ActiveConfig() *Config {
+	return activeConfig.Load()
+}
+
+// SetConfig 设置内置检测器使用的配置, cfg 需已通过 Validate
+// 通常在启动时调用一次; 正在进行的分析继续使用旧配置。
+func SetConfig(cfg *Config) {
+	activeConfig.Store(cfg)
+}
@@ -0,0 +1,34 @@
+package strategy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateChangeWindow(t *testing.T) {
+	tests := []struct {
+		lookback, window int
+		ok               bool
+	}{
+		{48, 0, true}, // 默认值随 lookback 变化
+		{48, 47, true},
+		{48, 48, false},
+		{96, 96, false},
+		{96, -1, false},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Lookback = tt.lookback
+		cfg.Detectors.OpenInterest.ChangeWindow = tt.window
+		err := cfg.Validate()
+		if tt.ok {
+			if err != nil {
+				t.Errorf("lookback %d, change_window %d: %v", tt.lookback, tt.window, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), "change_window") || !strings.Contains(err.Error(), "lookback") {
+			t.Errorf("lookback %d, change_window %d: error = %v, want one naming change_window and lookback", tt.lookback, tt.window, err)
+		}
+	}
+}
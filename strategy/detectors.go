@@ -1,4 +1,3 @@
-
 package strategy
 
 import (
@@ -9,31 +8,45 @@ import (
 	"time"
 )
 
-// DetectVolumeSignal 检测成交量异常信号
-func DetectVolumeSignal(klines []models.KlineData) *models.Signal {
-	const ZScoreThreshold = 2.0
-
-	if len(klines) < 2 {
+// DetectVolumeSignal 检测成交量异常信号, 方向取最新K线的涨跌
+// cfg.Window 限定参与计算的最近周期数, cfg.LogScale 时对成交量取对数后计算分数。
+// 对齐时补齐的零成交量K线 (Synthetic) 不参与统计, 最新K线为补齐K线时不产生信号。
+func DetectVolumeSignal(klines []models.KlineData, cfg VolumeConfig) *models.Signal {
+	klines = tail(klines, cfg.Window)
+	if len(klines) < 2 || klines[len(klines)-1].Synthetic {
 		return nil
 	}
 
-	volumes := make([]float64, len(klines))
-	for i, k := range klines {
-		volumes[i] = k.Volume
+	var volumes []float64
+	for _, k := range klines {
+		if !k.Synthetic {
+			volumes = append(volumes, k.Volume)
+		}
+	}
+	if len(volumes) < 2 {
+		return nil
 	}
 
-	zScore := CalculateZScore(volumes)
+	scored, label := volumes, "成交量"
+	if cfg.LogScale {
+		scored, label = LogScale(volumes), "对数成交量"
+	}
+	zScore := CalculateScore(scored, cfg.StatsConfig)
 
-	if math.Abs(zScore) > ZScoreThreshold {
+	if math.Abs(zScore) > cfg.ZScoreThreshold {
 		lastKline := klines[len(klines)-1]
 		signal := &models.Signal{
 			Symbol:      lastKline.Symbol,
 			SignalType:  models.VolumeSignal,
 			Timestamp:   time.Unix(0, lastKline.Timestamp*int64(time.Millisecond)),
-			Description: fmt.Sprintf("成交量 Z-Score: %.2f (阈值: %.1f)", zScore, ZScoreThreshold),
+			Description: fmt.Sprintf("%s %s: %.2f (阈值: %.1f)", label, cfg.Label(), zScore, cfg.ZScoreThreshold),
+			Direction:   directionOf(lastKline.Close - lastKline.Open),
+			Score:       strength(zScore, cfg.ZScoreThreshold),
 			Meta: map[string]interface{}{
-				"z_score":    zScore,
-				"threshold":  ZScoreThreshold,
+				"estimator":   cfg.Estimator,
+				"log_scale":   cfg.LogScale,
+				"z_score":     zScore,
+				"threshold":   cfg.ZScoreThreshold,
 				"mean_volume": CalculateMean(volumes),
 			},
 		}
@@ -44,72 +57,98 @@ func DetectVolumeSignal(klines []models.KlineData) *models.Signal {
 }
 
 // DetectOpenInterestSignal 检测持仓量异动信号
-func DetectOpenInterestSignal(ois []models.BinanceOI) []*models.Signal {
+// 方向取同一区间的价格变动: 增仓上涨 (新多头) 与减仓上涨 (空头回补) 看涨,
+// 增仓下跌 (新空头) 与减仓下跌 (多头出清) 看跌。klines 需与 ois 对齐, 缺失时方向为中性。
+func DetectOpenInterestSignal(ois []models.BinanceOI, klines []models.KlineData, cfg OpenInterestConfig) []*models.Signal {
 	var signals []*models.Signal
-	if len(ois) < 96 { // 需要足够的数据用于24小时比较
+	if len(ois) < 2 {
 		return signals
 	}
 
 	lastOI := ois[len(ois)-1]
 	lastOIFloat, _ := strconv.ParseFloat(lastOI.SumOpenInterest, 64)
 
-	// 模式1: 24小时变化 > 10%
-	oi24hAgo := ois[len(ois)-96]
-	oi24hAgoFloat, _ := strconv.ParseFloat(oi24hAgo.SumOpenInterest, 64)
-	if oi24hAgoFloat > 0 {
-		change24h := (lastOIFloat - oi24hAgoFloat) / oi24hAgoFloat * 100
-		if math.Abs(change24h) > 10.0 {
-			signals = append(signals, &models.Signal{
-				Symbol:      lastOI.Symbol,
-				SignalType:  models.OpenInterestSignal,
-				Timestamp:   time.Unix(0, lastOI.Timestamp*int64(time.Millisecond)),
-				Description: fmt.Sprintf("24小时OI变化: %.2f%% (阈值: 10%%)", change24h),
-				Meta: map[string]interface{}{"change_percent_24h": change24h},
-			})
+	// 模式1: 最近 window 个周期的变化超过阈值 (默认整个回溯窗口, 约24小时 > 10%)
+	// window 为 0 时跨越全部数据; 数据不足 window 个周期时跳过
+	window := cfg.ChangeWindow
+	if window == 0 {
+		window = len(ois) - 1
+	}
+	if len(ois) > window {
+		baseOI := ois[len(ois)-1-window]
+		baseOIFloat, _ := strconv.ParseFloat(baseOI.SumOpenInterest, 64)
+		if baseOIFloat > 0 {
+			change := (lastOIFloat - baseOIFloat) / baseOIFloat * 100
+			if math.Abs(change) > cfg.ChangePercent {
+				signals = append(signals, &models.Signal{
+					Symbol:      lastOI.Symbol,
+					SignalType:  models.OpenInterestSignal,
+					Timestamp:   time.Unix(0, lastOI.Timestamp*int64(time.Millisecond)),
+					Description: fmt.Sprintf("%d个周期OI变化: %.2f%% (阈值: %.1f%%)", window, change, cfg.ChangePercent),
+					Direction:   priceDirection(klines, baseOI.Timestamp, lastOI.Timestamp),
+					Score:       strength(change, cfg.ChangePercent),
+					Meta:        map[string]interface{}{"change_percent": change, "change_periods": window, "threshold": cfg.ChangePercent},
+				})
+			}
 		}
 	}
 
-	// 模式2: 连续4个周期上涨/下跌
-	if len(ois) >= 5 {
-		consecutiveRises := 0
-		consecutiveFalls := 0
-		for i := len(ois) - 5; i < len(ois)-1; i++ {
-			current, _ := strconv.ParseFloat(ois[i+1].SumOpenInterest, 64)
-			prev, _ := strconv.ParseFloat(ois[i].SumOpenInterest, 64)
-			if current > prev {
-				consecutiveRises++
-			} else if current < prev {
-				consecutiveFalls++
-			}
+	// 模式2: 连续至少N个周期上涨/下跌 (默认4个)
+	// run 是截至最新周期的同向变化周期数; Score 取连续周期数相对 N 的倍数与累计变化
+	// 相对单周期阈值的倍数中较大者, 使持续更久或累计幅度更大的趋势得分更高
+	n := cfg.ConsecutivePeriods
+	run, rising := 0, false
+	for i := len(ois) - 1; i > 0; i-- {
+		current, _ := strconv.ParseFloat(ois[i].SumOpenInterest, 64)
+		prev, _ := strconv.ParseFloat(ois[i-1].SumOpenInterest, 64)
+		if current == prev || (run > 0 && (current > prev) != rising) {
+			break
 		}
-		if consecutiveRises == 4 || consecutiveFalls == 4 {
-			desc := fmt.Sprintf("OI连续4个周期上涨")
-			if consecutiveFalls == 4 {
-				desc = fmt.Sprintf("OI连续4个周期下跌")
-			}
-			signals = append(signals, &models.Signal{
-				Symbol:      lastOI.Symbol,
-				SignalType:  models.OpenInterestSignal,
-				Timestamp:   time.Unix(0, lastOI.Timestamp*int64(time.Millisecond)),
-				Description: desc,
-				Meta: map[string]interface{}{"consecutive_periods": 4, "direction": map[bool]string{true: "rise", false: "fall"}[consecutiveRises == 4]},
-			})
+		rising = current > prev
+		run++
+	}
+	if run >= n {
+		baseOI := ois[len(ois)-1-run]
+		baseOIFloat, _ := strconv.ParseFloat(baseOI.SumOpenInterest, 64)
+		cumulative := 0.0
+		if baseOIFloat > 0 {
+			cumulative = (lastOIFloat - baseOIFloat) / baseOIFloat * 100
+		}
+		desc := fmt.Sprintf("OI连续%d个周期上涨, 累计 %.2f%%", run, cumulative)
+		if !rising {
+			desc = fmt.Sprintf("OI连续%d个周期下跌, 累计 %.2f%%", run, cumulative)
 		}
+		signals = append(signals, &models.Signal{
+			Symbol:      lastOI.Symbol,
+			SignalType:  models.OpenInterestSignal,
+			Timestamp:   time.Unix(0, lastOI.Timestamp*int64(time.Millisecond)),
+			Description: desc,
+			Direction:   priceDirection(klines, baseOI.Timestamp, lastOI.Timestamp),
+			Score:       math.Max(float64(run)/float64(n), strength(cumulative, cfg.SinglePeriodPercent)),
+			Meta: map[string]interface{}{
+				"consecutive_periods":       run,
+				"min_periods":               n,
+				"cumulative_change_percent": cumulative,
+				"direction":                 map[bool]string{true: "rise", false: "fall"}[rising],
+			},
+		})
 	}
 
-	// 模式3: 单周期剧烈变化 > 3.5%
+	// 模式3: 单周期剧烈变化 (默认 > 3.5%)
 	if len(ois) >= 2 {
 		prevOI := ois[len(ois)-2]
 		prevOIFloat, _ := strconv.ParseFloat(prevOI.SumOpenInterest, 64)
 		if prevOIFloat > 0 {
 			change1p := (lastOIFloat - prevOIFloat) / prevOIFloat * 100
-			if math.Abs(change1p) > 3.5 {
+			if math.Abs(change1p) > cfg.SinglePeriodPercent {
 				signals = append(signals, &models.Signal{
 					Symbol:      lastOI.Symbol,
 					SignalType:  models.OpenInterestSignal,
 					Timestamp:   time.Unix(0, lastOI.Timestamp*int64(time.Millisecond)),
-					Description: fmt.Sprintf("单周期OI剧烈变化: %.2f%% (阈值: 3.5%%)", change1p),
-					Meta: map[string]interface{}{"change_percent_1p": change1p},
+					Description: fmt.Sprintf("单周期OI剧烈变化: %.2f%% (阈值: %.1f%%)", change1p, cfg.SinglePeriodPercent),
+					Direction:   priceDirection(klines, prevOI.Timestamp, lastOI.Timestamp),
+					Score:       strength(change1p, cfg.SinglePeriodPercent),
+					Meta:        map[string]interface{}{"change_percent_1p": change1p, "threshold": cfg.SinglePeriodPercent},
 				})
 			}
 		}
@@ -118,10 +157,9 @@ func DetectOpenInterestSignal(ois []models.BinanceOI) []*models.Signal {
 	return signals
 }
 
-// DetectLSRatioSignal 检测多空比极端信号
-func DetectLSRatioSignal(lsRatios []models.GlobalLongShortRatio) *models.Signal {
-	const ZScoreThreshold = 2.0
-
+// DetectLSRatioSignal 检测多空比极端信号, 方向按逆向解读: 散户极度偏多时看跌
+func DetectLSRatioSignal(lsRatios []models.GlobalLongShortRatio, cfg LSRatioConfig) *models.Signal {
+	lsRatios = tail(lsRatios, cfg.Window)
 	if len(lsRatios) < 2 {
 		return nil
 	}
@@ -131,18 +169,21 @@ func DetectLSRatioSignal(lsRatios []models.GlobalLongShortRatio) *models.Signal
 		ratios[i], _ = strconv.ParseFloat(r.LongShortRatio, 64)
 	}
 
-	zScore := CalculateZScore(ratios)
+	zScore := CalculateScore(ratios, cfg.StatsConfig)
 
-	if math.Abs(zScore) > ZScoreThreshold {
+	if math.Abs(zScore) > cfg.ZScoreThreshold {
 		lastRatio := lsRatios[len(lsRatios)-1]
 		signal := &models.Signal{
 			Symbol:      lastRatio.Symbol,
 			SignalType:  models.LSRatioSignal,
 			Timestamp:   time.Unix(0, lastRatio.Timestamp*int64(time.Millisecond)),
-			Description: fmt.Sprintf("多空账户比 Z-Score: %.2f (阈值: %.1f), 市场情绪可能极端。", zScore, ZScoreThreshold),
+			Description: fmt.Sprintf("多空账户比 %s: %.2f (阈值: %.1f), 市场情绪可能极端。", cfg.Label(), zScore, cfg.ZScoreThreshold),
+			Direction:   directionOf(-zScore),
+			Score:       strength(zScore, cfg.ZScoreThreshold),
 			Meta: map[string]interface{}{
+				"estimator": cfg.Estimator,
 				"z_score":   zScore,
-				"threshold": ZScoreThreshold,
+				"threshold": cfg.ZScoreThreshold,
 				"ls_ratio":  ratios[len(ratios)-1],
 			},
 		}
@@ -151,3 +192,549 @@ func DetectLSRatioSignal(lsRatios []models.GlobalLongShortRatio) *models.Signal
 
 	return nil
 }
+
+// DetectTakerImbalanceSignal 检测主动买卖失衡信号
+// 优先使用 takerlongshortRatio 的买卖比序列, 不可用时退回K线中的主动买入量。
+// 比值取对数后再计算 Z-Score, 使买方与卖方的极端程度对称。
+func DetectTakerImbalanceSignal(symbol string, takerRatios []models.TakerLongShortRatio, klines []models.KlineData, cfg TakerImbalanceConfig) *models.Signal {
+	takerRatios, klines = tail(takerRatios, cfg.Window), tail(klines, cfg.Window)
+
+	// lastTimestamp 是最后一个参与计算的点的时间, newestTimestamp 是原始序列最新一根的时间
+	var logRatios []float64
+	var lastTimestamp, newestTimestamp int64
+	source := "takerlongshortRatio"
+	if len(takerRatios) >= 2 {
+		for _, r := range takerRatios {
+			ratio, _ := strconv.ParseFloat(r.BuySellRatio, 64)
+			if ratio <= 0 {
+				continue
+			}
+			logRatios = append(logRatios, math.Log(ratio))
+			lastTimestamp = r.Timestamp
+		}
+		newestTimestamp = takerRatios[len(takerRatios)-1].Timestamp
+	} else {
+		source = "klines"
+		for _, k := range klines {
+			sellVolume := k.Volume - k.TakerBuyVolume
+			if k.TakerBuyVolume <= 0 || sellVolume <= 0 {
+				continue
+			}
+			logRatios = append(logRatios, math.Log(k.TakerBuyVolume/sellVolume))
+			lastTimestamp = k.Timestamp
+		}
+		if len(klines) > 0 {
+			newestTimestamp = klines[len(klines)-1].Timestamp
+		}
+	}
+
+	// 最新一根被跳过时不能用更早一根的 Z-Score 冒充最新信号
+	if len(logRatios) < 2 || lastTimestamp != newestTimestamp {
+		return nil
+	}
+
+	zScore := CalculateScore(logRatios, cfg.StatsConfig)
+
+	if math.Abs(zScore) > cfg.ZScoreThreshold {
+		buySellRatio := math.Exp(logRatios[len(logRatios)-1])
+		side := "买方"
+		if zScore < 0 {
+			side = "卖方"
+		}
+		signal := &models.Signal{
+			Symbol:      symbol,
+			SignalType:  models.TakerImbalanceSignal,
+			Timestamp:   time.Unix(0, lastTimestamp*int64(time.Millisecond)),
+			Description: fmt.Sprintf("主动%s力量极端, 买卖比: %.4f, %s: %.2f (阈值: %.1f)", side, buySellRatio, cfg.Label(), zScore, cfg.ZScoreThreshold),
+			Direction:   directionOf(zScore),
+			Score:       strength(zScore, cfg.ZScoreThreshold),
+			Meta: map[string]interface{}{
+				"estimator":      cfg.Estimator,
+				"z_score":        zScore,
+				"threshold":      cfg.ZScoreThreshold,
+				"buy_sell_ratio": buySellRatio,
+				"source":         source,
+			},
+		}
+		return signal
+	}
+
+	return nil
+}
+
+// DetectSmartMoneyDivergenceSignal 检测大户与散户 (全市场账户) 多空方向背离信号
+// basis 标明大户数据的统计口径 ("position" 或 "account"); 双方的多头占比需分别
+// 偏离 50% 至少 cfg.Margin 且方向相反。方向跟随大户。
+func DetectSmartMoneyDivergenceSignal(topRatios []models.TopLongShortRatio, basis string, globalRatios []models.GlobalLongShortRatio, cfg SmartMoneyConfig) *models.Signal {
+	margin := cfg.Margin
+	if len(topRatios) == 0 || len(globalRatios) == 0 || margin <= 0 {
+		return nil
+	}
+
+	lastTop := topRatios[len(topRatios)-1]
+	lastGlobal := globalRatios[len(globalRatios)-1]
+	topLong, err1 := strconv.ParseFloat(lastTop.LongAccount, 64)
+	crowdLong, err2 := strconv.ParseFloat(lastGlobal.LongAccount, 64)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+
+	topLean := topLong - 0.5
+	crowdLean := crowdLong - 0.5
+	if math.Abs(topLean) < margin || math.Abs(crowdLean) < margin || topLean*crowdLean > 0 {
+		return nil
+	}
+
+	smartSide, crowdSide := "long", "short"
+	desc := "大户偏多而散户偏空"
+	direction := models.Bullish
+	if topLean < 0 {
+		smartSide, crowdSide = "short", "long"
+		desc = "大户偏空而散户偏多"
+		direction = models.Bearish
+	}
+
+	return &models.Signal{
+		Symbol:      lastTop.Symbol,
+		SignalType:  models.SmartMoneyDivergenceSignal,
+		Timestamp:   time.Unix(0, lastTop.Timestamp*int64(time.Millisecond)),
+		Description: fmt.Sprintf("%s: 大户多头占比 %.2f%%, 散户多头占比 %.2f%% (阈值: ±%.1f%%)", desc, topLong*100, crowdLong*100, margin*100),
+		Direction:   direction,
+		Score:       strength(math.Min(math.Abs(topLean), math.Abs(crowdLean)), margin),
+		Meta: map[string]interface{}{
+			"top_long_share":   topLong,
+			"crowd_long_share": crowdLong,
+			"top_basis":        basis,
+			"smart_money_side": smartSide,
+			"crowd_side":       crowdSide,
+			"margin":           margin,
+		},
+	}
+}
+
+// DetectFundingSignal 检测资金费率异常信号
+// premium 可为 nil, 此时仅基于已结算的历史资金费率判断。
+// 费率极端按逆向解读 (多头拥挤时看跌), 费率翻转的方向为费率变化的方向。
+func DetectFundingSignal(rates []models.FundingRate, premium *models.PremiumIndex, cfg FundingConfig) []*models.Signal {
+	var signals []*models.Signal
+	if len(rates) == 0 {
+		return signals
+	}
+
+	lastRate := rates[len(rates)-1]
+	settled, err := strconv.ParseFloat(lastRate.FundingRate, 64)
+	if err != nil {
+		return signals
+	}
+
+	// 以预测费率为准, 没有溢价指数时退回最近一期已结算费率
+	// 有预测费率时在 Meta 中附带预测费率与下次结算时间, 便于在通知卡片中展示
+	current, label := settled, "上期资金费率"
+	symbol, timestamp := lastRate.Symbol, lastRate.FundingTime
+	var predictedMeta map[string]interface{}
+	if premium != nil {
+		if predicted, err := strconv.ParseFloat(premium.LastFundingRate, 64); err == nil {
+			current, label = predicted, "预测资金费率"
+			symbol, timestamp = premium.Symbol, premium.Time
+			predictedMeta = map[string]interface{}{
+				"predicted_funding_rate": predicted,
+				"next_funding_time":      premium.NextFundingTime,
+			}
+		}
+	}
+
+	// 模式1: 费率绝对值极端
+	if math.Abs(current) >= cfg.ExtremeThreshold {
+		side := "多头"
+		if current < 0 {
+			side = "空头"
+		}
+		signals = append(signals, &models.Signal{
+			Symbol:      symbol,
+			SignalType:  models.FundingSignal,
+			Timestamp:   time.Unix(0, timestamp*int64(time.Millisecond)),
+			Description: fmt.Sprintf("%s极端: %.4f%% (阈值: ±%.2f%%), %s拥挤", label, current*100, cfg.ExtremeThreshold*100, side),
+			Direction:   directionOf(-current),
+			Score:       strength(current, cfg.ExtremeThreshold),
+			Meta: map[string]interface{}{
+				"funding_rate": current,
+				"threshold":    cfg.ExtremeThreshold,
+				"mode":         "extreme",
+			},
+		})
+	}
+
+	// 模式2: 费率快速翻转 (预测费率与上期已结算费率方向相反且变化显著)
+	if premium != nil && current != settled && current*settled < 0 && math.Abs(current-settled) >= cfg.FlipMinChange {
+		signals = append(signals, &models.Signal{
+			Symbol:      symbol,
+			SignalType:  models.FundingSignal,
+			Timestamp:   time.Unix(0, timestamp*int64(time.Millisecond)),
+			Description: fmt.Sprintf("资金费率快速翻转: %.4f%% -> %.4f%%", settled*100, current*100),
+			Direction:   directionOf(current - settled),
+			Score:       strength(current-settled, cfg.FlipMinChange),
+			Meta: map[string]interface{}{
+				"previous_funding_rate": settled,
+				"funding_rate":          current,
+				"min_change":            cfg.FlipMinChange,
+				"mode":                  "flip",
+			},
+		})
+	}
+
+	for _, signal := range signals {
+		for k, v := range predictedMeta {
+			signal.Meta[k] = v
+		}
+	}
+	return signals
+}
+
+// DetectLiquidationCascadeSignal 检测强平瀑布信号
+// 最新周期某一方向的强平名义价值远超此前周期的平均水平, 且价格同时向该方向不利地变动。
+// liqs 需与 klines 一一对应 (参见 AggregateLiquidations)。多头强平看跌, 空头强平看涨。
+// 标记为 Unknown 的周期不计入基线; 最新周期未知或没有已知的历史周期时不触发。
+func DetectLiquidationCascadeSignal(liqs []models.LiquidationData, klines []models.KlineData, cfg LiquidationConfig) *models.Signal {
+	if len(liqs) < 2 || len(liqs) != len(klines) {
+		return nil
+	}
+
+	last := liqs[len(liqs)-1]
+	if last.Unknown {
+		return nil
+	}
+	var longHist, shortHist []float64
+	for _, l := range liqs[:len(liqs)-1] {
+		if l.Unknown {
+			continue
+		}
+		longHist = append(longHist, l.LongNotional)
+		shortHist = append(shortHist, l.ShortNotional)
+	}
+	if len(longHist) == 0 {
+		return nil
+	}
+
+	lastKline := klines[len(klines)-1]
+	if lastKline.Open <= 0 {
+		return nil
+	}
+	priceChange := (lastKline.Close - lastKline.Open) / lastKline.Open * 100
+
+	side, notional, baseline := "", 0.0, 0.0
+	longBaseline, shortBaseline := CalculateMean(longHist), CalculateMean(shortHist)
+	// 多头强平需伴随下跌, 空头强平需伴随上涨
+	if last.LongNotional >= cfg.MinNotional && last.LongNotional >= cfg.BaselineMultiplier*longBaseline && priceChange <= -cfg.MinPriceMove {
+		side, notional, baseline = "long", last.LongNotional, longBaseline
+	}
+	if last.ShortNotional >= cfg.MinNotional && last.ShortNotional >= cfg.BaselineMultiplier*shortBaseline && priceChange >= cfg.MinPriceMove && last.ShortNotional > notional {
+		side, notional, baseline = "short", last.ShortNotional, shortBaseline
+	}
+	if side == "" {
+		return nil
+	}
+
+	desc := fmt.Sprintf("多头强平瀑布: 强平 %.0f USDT (基线 %.0f), 价格 %.2f%%, 区间 %.4f - %.4f", notional, baseline, priceChange, lastKline.Low, lastKline.High)
+	direction := models.Bearish
+	if side == "short" {
+		desc = fmt.Sprintf("空头强平瀑布: 强平 %.0f USDT (基线 %.0f), 价格 +%.2f%%, 区间 %.4f - %.4f", notional, baseline, priceChange, lastKline.Low, lastKline.High)
+		direction = models.Bullish
+	}
+
+	return &models.Signal{
+		Symbol:      last.Symbol,
+		SignalType:  models.LiquidationSignal,
+		Timestamp:   time.Unix(0, last.Timestamp*int64(time.Millisecond)),
+		Description: desc,
+		Direction:   direction,
+		// 强度相对于触发所需的强平额 (基线倍数与最小名义价值中的较大者)
+		Score: strength(notional, math.Max(cfg.BaselineMultiplier*baseline, cfg.MinNotional)),
+		Meta: map[string]interface{}{
+			"liquidated_side":      side,
+			"notional":             notional,
+			"baseline_notional":    baseline,
+			"multiplier":           cfg.BaselineMultiplier,
+			"price_change_percent": priceChange,
+			"price_low":            lastKline.Low,
+			"price_high":           lastKline.High,
+		},
+	}
+}
+
+// DetectOrderBookSignal 检测盘口买卖失衡与价格附近的大额挂单墙
+// liquidity 应由 CalculateDepthLiquidity 基于同一快照计算。买盘占优与买墙看涨, 卖盘占优与卖墙看跌。
+func DetectOrderBookSignal(book *models.OrderBook, liquidity []models.DepthLiquidity, cfg OrderBookConfig) []*models.Signal {
+	var signals []*models.Signal
+	mid := OrderBookMidPrice(book)
+	if mid == 0 {
+		return signals
+	}
+	timestamp := time.Unix(0, book.Timestamp*int64(time.Millisecond))
+
+	// 模式1: 买卖盘累计流动性失衡
+	for _, l := range liquidity {
+		if l.RangePercent != cfg.ImbalanceRange || l.BidNotional+l.AskNotional == 0 {
+			continue
+		}
+		imbalance := (l.BidNotional - l.AskNotional) / (l.BidNotional + l.AskNotional)
+		if math.Abs(imbalance) >= cfg.ImbalanceThreshold {
+			side := "买盘"
+			if imbalance < 0 {
+				side = "卖盘"
+			}
+			signals = append(signals, &models.Signal{
+				Symbol:      book.Symbol,
+				SignalType:  models.OrderBookSignal,
+				Timestamp:   timestamp,
+				Description: fmt.Sprintf("盘口±%.0f%%内%s占优, 失衡度: %.2f (阈值: ±%.1f), 买盘 %.0f / 卖盘 %.0f USDT", cfg.ImbalanceRange, side, imbalance, cfg.ImbalanceThreshold, l.BidNotional, l.AskNotional),
+				Direction:   directionOf(imbalance),
+				Score:       boundedStrength(imbalance, cfg.ImbalanceThreshold, 1),
+				Meta: map[string]interface{}{
+					"imbalance":     imbalance,
+					"threshold":     cfg.ImbalanceThreshold,
+					"range_percent": cfg.ImbalanceRange,
+					"bid_notional":  l.BidNotional,
+					"ask_notional":  l.AskNotional,
+				},
+			})
+		}
+	}
+
+	// 模式2: 价格附近的大额挂单墙 (每侧只报告最大的一档)
+	findWall := func(levels []models.OrderBookLevel, inRange func(price float64) bool) (models.OrderBookLevel, float64, bool) {
+		var notionals []float64
+		var wall models.OrderBookLevel
+		for _, l := range levels {
+			if !inRange(l.Price) {
+				break
+			}
+			n := l.Price * l.Quantity
+			notionals = append(notionals, n)
+			if n > wall.Price*wall.Quantity {
+				wall = l
+			}
+		}
+		median := CalculateMedian(notionals)
+		if len(notionals) < cfg.MinWallLevels || median == 0 {
+			return wall, 0, false
+		}
+		return wall, median, wall.Price*wall.Quantity >= cfg.WallMultiplier*median
+	}
+	walls := []struct {
+		side      string
+		label     string
+		direction models.Direction
+		levels    []models.OrderBookLevel
+		in        func(float64) bool
+	}{
+		{"bid", "买墙 (支撑)", models.Bullish, book.Bids, func(p float64) bool { return p >= mid*(1-cfg.WallRange/100) }},
+		{"ask", "卖墙 (阻力)", models.Bearish, book.Asks, func(p float64) bool { return p <= mid*(1+cfg.WallRange/100) }},
+	}
+	for _, w := range walls {
+		wall, median, ok := findWall(w.levels, w.in)
+		if !ok {
+			continue
+		}
+		notional := wall.Price * wall.Quantity
+		distance := (wall.Price - mid) / mid * 100
+		signals = append(signals, &models.Signal{
+			Symbol:      book.Symbol,
+			SignalType:  models.OrderBookSignal,
+			Timestamp:   timestamp,
+			Description: fmt.Sprintf("%s: 价格 %.4f (距中间价 %.2f%%), 挂单 %.0f USDT, 为中位数的 %.1f 倍", w.label, wall.Price, distance, notional, notional/median),
+			Direction:   w.direction,
+			Score:       strength(notional, cfg.WallMultiplier*median),
+			Meta: map[string]interface{}{
+				"wall_side":        w.side,
+				"wall_price":       wall.Price,
+				"wall_notional":    notional,
+				"distance_percent": distance,
+				"median_notional":  median,
+				"multiplier":       cfg.WallMultiplier,
+			},
+		})
+	}
+
+	return signals
+}
+
+// DetectBasisSignal 检测基差异常扩张 (相对自身历史) 与基差倒挂信号
+// 信号归属于永续合约 symbol, 具体合约记录在 Meta 中。升水扩大或转为升水看涨, 反之看跌。
+func DetectBasisSignal(symbol string, series []models.BasisSeries, cfg BasisConfig) []*models.Signal {
+	var signals []*models.Signal
+	for _, s := range series {
+		if len(s.Points) < 2 {
+			continue
+		}
+
+		annualized := make([]float64, len(s.Points))
+		for i, p := range s.Points {
+			annualized[i] = p.Annualized
+		}
+		last := s.Points[len(s.Points)-1]
+		timestamp := time.Unix(0, last.Timestamp*int64(time.Millisecond))
+
+		// 永续合约按未年化溢价设下限, 交割合约按年化基差设下限
+		level, blowoutFloor, inversionFloor := last.Annualized, cfg.MinAnnualized, cfg.MinInversion
+		if s.ContractType == "PERPETUAL" {
+			level, blowoutFloor, inversionFloor = last.Basis, cfg.MinPerpPremium, cfg.MinPerpPremium
+		}
+
+		// 模式1: 基差相对自身历史异常扩张
+		zScore := CalculateScore(annualized, cfg.StatsConfig)
+		if math.Abs(zScore) > cfg.ZScoreThreshold && math.Abs(level) >= blowoutFloor {
+			signals = append(signals, &models.Signal{
+				Symbol:      symbol,
+				SignalType:  models.BasisSignal,
+				Timestamp:   timestamp,
+				Description: fmt.Sprintf("%s 基差异常: 年化 %.2f%%, %s: %.2f (阈值: %.1f)", s.ContractType, last.Annualized*100, cfg.Label(), zScore, cfg.ZScoreThreshold),
+				Direction:   directionOf(zScore),
+				Score:       strength(zScore, cfg.ZScoreThreshold),
+				Meta: map[string]interface{}{
+					"contract":         s.Symbol,
+					"contract_type":    s.ContractType,
+					"basis":            last.Basis,
+					"annualized_basis": last.Annualized,
+					"estimator":        cfg.Estimator,
+					"z_score":          zScore,
+					"threshold":        cfg.ZScoreThreshold,
+					"mode":             "blowout",
+				},
+			})
+		}
+
+		// 模式2: 基差方向与历史中位数相反 (升水转贴水或相反)
+		median := CalculateMedian(annualized[:len(annualized)-1])
+		if median*last.Annualized < 0 && math.Abs(level) >= inversionFloor {
+			desc := "升水转为贴水"
+			if last.Annualized > 0 {
+				desc = "贴水转为升水"
+			}
+			signals = append(signals, &models.Signal{
+				Symbol:      symbol,
+				SignalType:  models.BasisSignal,
+				Timestamp:   timestamp,
+				Description: fmt.Sprintf("%s 基差倒挂, %s: 年化 %.2f%% (历史中位数 %.2f%%)", s.ContractType, desc, last.Annualized*100, median*100),
+				Direction:   directionOf(last.Annualized),
+				Score:       strength(level, inversionFloor),
+				Meta: map[string]interface{}{
+					"contract":          s.Symbol,
+					"contract_type":     s.ContractType,
+					"basis":             last.Basis,
+					"annualized_basis":  last.Annualized,
+					"median_annualized": median,
+					"mode":              "inversion",
+				},
+			})
+		}
+	}
+	return signals
+}
+
+// DetectSpotPerpVolumeSignal 检测期现成交额比异常信号
+// 合约成交额远超现货说明行情由杠杆投机驱动, 现货成交额相对放大则说明由现货买卖主导。
+// 比值取对数后与自身历史比较, 以消除不同币种期现比基准水平的差异。
+// 现货主导时方向取最新K线的涨跌, 合约主导 (投机驱动) 时方向为中性。
+func DetectSpotPerpVolumeSignal(perp, spot []models.KlineData, cfg SpotPerpVolumeConfig) *models.Signal {
+	ratios := SpotPerpVolumeRatios(perp, spot)
+	if len(ratios) < cfg.MinPeriods {
+		return nil
+	}
+
+	logRatios := make([]float64, len(ratios))
+	for i, r := range ratios {
+		logRatios[i] = math.Log(r.Ratio)
+	}
+	zScore := CalculateScore(logRatios, cfg.StatsConfig)
+	last := ratios[len(ratios)-1]
+	median := CalculateMedian(ratioValues(ratios[:len(ratios)-1]))
+	if median <= 0 || math.Abs(zScore) <= cfg.ZScoreThreshold {
+		return nil
+	}
+
+	deviation := last.Ratio / median
+	mode, desc := "perp_led", "合约成交额远超现货, 行情偏投机驱动"
+	direction := models.Neutral
+	if deviation < 1 {
+		deviation = 1 / deviation
+		mode, desc = "spot_led", "现货成交额相对放大, 行情由现货主导"
+		lastKline := perp[len(perp)-1]
+		direction = directionOf(lastKline.Close - lastKline.Open)
+	}
+	if deviation < cfg.MinDeviation {
+		return nil
+	}
+
+	return &models.Signal{
+		Symbol:      perp[len(perp)-1].Symbol,
+		SignalType:  models.SpotPerpVolumeSignal,
+		Timestamp:   time.Unix(0, last.Timestamp*int64(time.Millisecond)),
+		Description: fmt.Sprintf("%s: 期现成交额比 %.2f (历史中位数 %.2f), %s: %.2f (阈值: %.1f)", desc, last.Ratio, median, cfg.Label(), zScore, cfg.ZScoreThreshold),
+		Direction:   direction,
+		Score:       strength(zScore, cfg.ZScoreThreshold),
+		Meta: map[string]interface{}{
+			"perp_volume":  last.PerpVolume,
+			"spot_volume":  last.SpotVolume,
+			"ratio":        last.Ratio,
+			"median_ratio": median,
+			"estimator":    cfg.Estimator,
+			"z_score":      zScore,
+			"threshold":    cfg.ZScoreThreshold,
+			"mode":         mode,
+		},
+	}
+}
+
+// tail 返回 series 的最近 n 个元素, n <= 0 时返回全部
+func tail[T any](series []T, n int) []T {
+	if n <= 0 || len(series) <= n {
+		return series
+	}
+	return series[len(series)-n:]
+}
+
+// directionOf 按 v 的符号返回方向, 0 为中性
+func directionOf(v float64) models.Direction {
+	switch {
+	case v > 0:
+		return models.Bullish
+	case v < 0:
+		return models.Bearish
+	}
+	return models.Neutral
+}
+
+// priceDirection 返回开盘时间 from 至 to 的K线收盘价变动方向, K线缺失时为中性
+func priceDirection(klines []models.KlineData, from, to int64) models.Direction {
+	start, end := closeAt(klines, from), closeAt(klines, to)
+	if start <= 0 || end <= 0 {
+		return models.Neutral
+	}
+	return directionOf(end - start)
+}
+
+// strength 返回 |v| 相对阈值的倍数, 作为信号的 Score; 阈值不为正时返回 1
+func strength(v, threshold float64) float64 {
+	if threshold <= 0 {
+		return 1
+	}
+	return math.Abs(v) / threshold
+}
+
+// maxBoundedStrength 是 boundedStrength 的上限, 对应 |v| 到达边界时
+const maxBoundedStrength = 10
+
+// boundedStrength 是取值有界 (|v| <= bound) 的指标的 Score: 阈值处到边界的距离
+// 与 |v| 到边界的距离之比。|v| 等于阈值时为 1, 越接近边界越大, 最大为
+// maxBoundedStrength。strength 的线性倍数对这类指标最多只有 bound/threshold,
+// 无法达到较高的严重程度。
+func boundedStrength(v, threshold, bound float64) float64 {
+	if threshold <= 0 || threshold >= bound {
+		return 1
+	}
+	distance := bound - math.Abs(v)
+	if distance <= 0 {
+		return maxBoundedStrength
+	}
+	return math.Min((bound-threshold)/distance, maxBoundedStrength)
+}
@@ -0,0 +1,363 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// takerRatios 构造间隔一个周期的主动买卖比序列
+func takerRatios(ratios ...float64) []models.TakerLongShortRatio {
+	out := make([]models.TakerLongShortRatio, len(ratios))
+	for i, r := range ratios {
+		out[i] = models.TakerLongShortRatio{
+			BuySellRatio: strconv.FormatFloat(r, 'f', -1, 64),
+			Timestamp:    int64(i+1) * 900000,
+		}
+	}
+	return out
+}
+
+func TestDetectTakerImbalanceSignal(t *testing.T) {
+	cfg := DefaultConfig().Detectors.TakerImbalance
+	base := []float64{1, 1.02, 0.98, 1.01, 0.99, 1, 1.02, 0.98, 1.01, 0.99}
+
+	tests := []struct {
+		name   string
+		ratios []float64
+		want   int64 // 信号时间, 0 表示不应触发
+	}{
+		{"newest bar extreme", append(append([]float64{}, base...), 3), 11 * 900000},
+		{"newest bar skipped", append(append([]float64{}, base...), 3, 0), 0},
+		{"calm", base, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := DetectTakerImbalanceSignal("BTCUSDT", takerRatios(tt.ratios...), nil, cfg)
+			switch {
+			case tt.want == 0 && signal != nil:
+				t.Errorf("got signal at %d (%s), want none", signal.Timestamp.UnixMilli(), signal.Description)
+			case tt.want != 0 && signal == nil:
+				t.Errorf("got no signal, want one at %d", tt.want)
+			case tt.want != 0 && signal.Timestamp.UnixMilli() != tt.want:
+				t.Errorf("signal timestamp %d, want %d", signal.Timestamp.UnixMilli(), tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectFundingSignalMeta(t *testing.T) {
+	cfg := DefaultConfig().Detectors.Funding
+	rates := []models.FundingRate{{Symbol: "BTCUSDT", FundingRate: "0.0004", FundingTime: 1000}}
+	premium := &models.PremiumIndex{Symbol: "BTCUSDT", LastFundingRate: "-0.001", NextFundingTime: 2000, Time: 1500}
+
+	signals := DetectFundingSignal(rates, premium, cfg)
+	if len(signals) != 2 {
+		t.Fatalf("got %d signals, want extreme and flip", len(signals))
+	}
+	for _, s := range signals {
+		if s.Meta["predicted_funding_rate"] != -0.001 || s.Meta["next_funding_time"] != int64(2000) {
+			t.Errorf("%s: Meta = %v, want the predicted rate and next funding time", s.Meta["mode"], s.Meta)
+		}
+	}
+
+	// 没有溢价指数时只知道已结算费率, 不应附带预测费率
+	for _, s := range DetectFundingSignal([]models.FundingRate{{FundingRate: "0.001"}}, nil, cfg) {
+		if _, ok := s.Meta["predicted_funding_rate"]; ok {
+			t.Errorf("Meta = %v, want no predicted funding rate", s.Meta)
+		}
+	}
+}
+
+// cascadeKlines 构造 n 根间隔一个周期的K线, 最后一根下跌 2%
+func cascadeKlines(n int) []models.KlineData {
+	klines := make([]models.KlineData, n)
+	for i := range klines {
+		ts := int64(i) * 900000
+		klines[i] = models.KlineData{Symbol: "BTCUSDT", Timestamp: ts, CloseTime: ts + 899999, Open: 100, High: 100, Low: 100, Close: 100}
+	}
+	klines[n-1].Close, klines[n-1].Low = 98, 98
+	return klines
+}
+
+func TestAggregateLiquidations(t *testing.T) {
+	klines := cascadeKlines(4)
+	orders := []models.ForceOrder{
+		{Side: "SELL", ExecutedQty: "2", AveragePrice: "100", Time: 900000 + 10},
+		{Side: "BUY", OrigQty: "1", Price: "50", Time: 2*900000 + 10},
+		{Side: "SELL", ExecutedQty: "1", AveragePrice: "100", Time: 4*900000 + 10}, // 最后一根K线之后
+	}
+	liqs := AggregateLiquidations(orders, klines, []TimeRange{{From: math.MinInt64, To: 900000}})
+	want := []models.LiquidationData{
+		{Symbol: "BTCUSDT", Timestamp: 0, Unknown: true},
+		{Symbol: "BTCUSDT", Timestamp: 900000, LongNotional: 200},
+		{Symbol: "BTCUSDT", Timestamp: 2 * 900000, ShortNotional: 50},
+		{Symbol: "BTCUSDT", Timestamp: 3 * 900000},
+	}
+	for i := range want {
+		if liqs[i] != want[i] {
+			t.Errorf("bar %d: got %+v, want %+v", i, liqs[i], want[i])
+		}
+	}
+}
+
+func TestAggregateLiquidationsGaps(t *testing.T) {
+	klines := cascadeKlines(5)
+	// 断线期间落在第 1 根K线内, 以及从第 3 根K线中途开始仍未恢复
+	gaps := []TimeRange{
+		{From: 900000 + 100, To: 900000 + 200},
+		{From: 3*900000 + 500, To: math.MaxInt64},
+	}
+	liqs := AggregateLiquidations(nil, klines, gaps)
+	want := []bool{false, true, false, true, true}
+	for i, unknown := range want {
+		if liqs[i].Unknown != unknown {
+			t.Errorf("bar %d: Unknown = %v, want %v", i, liqs[i].Unknown, unknown)
+		}
+	}
+}
+
+func TestDetectLiquidationCascadeSignalUnknownBars(t *testing.T) {
+	cfg := DefaultConfig().Detectors.LiquidationCascade
+	klines := cascadeKlines(10)
+	liqs := make([]models.LiquidationData, len(klines))
+	for i, k := range klines {
+		liqs[i] = models.LiquidationData{Symbol: k.Symbol, Timestamp: k.Timestamp, LongNotional: 100000}
+	}
+	liqs[len(liqs)-1].LongNotional = 300000
+
+	// 历史强平远低于最新周期时触发
+	for i := range liqs[:len(liqs)-1] {
+		liqs[i].LongNotional = 10000
+	}
+	if s := DetectLiquidationCascadeSignal(liqs, klines, cfg); s == nil || s.Direction != models.Bearish {
+		t.Fatalf("got %+v, want a bearish cascade", s)
+	}
+	for i := range liqs[:len(liqs)-1] {
+		liqs[i].LongNotional = 100000
+	}
+
+	// 已知基线为 100000, 300000 不到 5 倍基线, 不应触发
+	if s := DetectLiquidationCascadeSignal(liqs, klines, cfg); s != nil {
+		t.Fatalf("got %s, want no signal against a known baseline", s.Description)
+	}
+
+	// 未知周期若按零处理会拉低基线并误报瀑布, 应被排除在基线之外
+	for i := 0; i < 7; i++ {
+		liqs[i] = models.LiquidationData{Symbol: "BTCUSDT", Timestamp: klines[i].Timestamp, Unknown: true}
+	}
+	if s := DetectLiquidationCascadeSignal(liqs, klines, cfg); s != nil {
+		t.Fatalf("got %s, want unknown bars left out of the baseline", s.Description)
+	}
+
+	// 没有已知的历史周期或最新周期未知时不触发
+	for i := range liqs[:len(liqs)-1] {
+		liqs[i].Unknown = true
+	}
+	if s := DetectLiquidationCascadeSignal(liqs, klines, cfg); s != nil {
+		t.Errorf("got %s, want no signal without a known baseline", s.Description)
+	}
+	liqs[len(liqs)-1].Unknown = true
+	if s := DetectLiquidationCascadeSignal(liqs, klines, cfg); s != nil {
+		t.Errorf("got %s, want no signal for an unknown newest bar", s.Description)
+	}
+}
+
+// basisSeries 构造合约收盘价相对指数的溢价序列
+func basisSeries(contractType string, annualize float64, premiums ...float64) []models.BasisSeries {
+	points := make([]models.BasisPoint, len(premiums))
+	for i, p := range premiums {
+		points[i] = models.BasisPoint{Timestamp: int64(i) * 900000, Price: 100 * (1 + p), IndexPrice: 100, Basis: p, Annualized: p * annualize}
+	}
+	return []models.BasisSeries{{Symbol: "BTCUSDT", ContractType: contractType, Points: points}}
+}
+
+func TestDetectBasisSignalPerpPremiumFloor(t *testing.T) {
+	cfg := DefaultConfig().Detectors.Basis
+	calm := []float64{0.00001, 0.00002, 0.00001, 0.00003, 0.00002, 0.00001, 0.00002, 0.00001, 0.00003, 0.00002}
+
+	// 0.02% 的溢价年化后约 22%, 超过年化下限, 但未达到永续合约的溢价下限
+	small := append(append([]float64{}, calm...), 0.0002)
+	if signals := DetectBasisSignal("BTCUSDT", basisSeries("PERPETUAL", perpFundingPeriodsPerYear, small...), cfg); len(signals) != 0 {
+		t.Errorf("got %s, want no signal below min_perp_premium", signals[0].Description)
+	}
+
+	large := append(append([]float64{}, calm...), 0.002)
+	signals := DetectBasisSignal("BTCUSDT", basisSeries("PERPETUAL", perpFundingPeriodsPerYear, large...), cfg)
+	if len(signals) != 1 || signals[0].Meta["mode"] != "blowout" {
+		t.Fatalf("got %d signals, want one blowout", len(signals))
+	}
+
+	// 永续合约溢价在零附近的小幅翻转不算倒挂
+	flip := append(append([]float64{}, calm...), -0.00005)
+	for _, s := range DetectBasisSignal("BTCUSDT", basisSeries("PERPETUAL", perpFundingPeriodsPerYear, flip...), cfg) {
+		if s.Meta["mode"] == "inversion" {
+			t.Errorf("got %s, want no inversion below min_perp_premium", s.Description)
+		}
+	}
+}
+
+func TestDetectOpenInterestSignalChangeWindow(t *testing.T) {
+	values := []float64{50, 100, 100, 100, 100, 120}
+	ois := make([]models.BinanceOI, len(values))
+	for i, v := range values {
+		ois[i] = models.BinanceOI{SumOpenInterest: strconv.FormatFloat(v, 'f', -1, 64), Timestamp: int64(i+1) * 900000}
+	}
+
+	tests := []struct {
+		window      int
+		wantPeriods int // 0 表示不应触发
+		wantChange  float64
+	}{
+		{0, 5, 140}, // 跨越全部数据
+		{5, 5, 140},
+		{1, 1, 20}, // 与 1 个周期前比较
+		{6, 0, 0},  // 数据不足
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig().Detectors.OpenInterest
+		cfg.ChangeWindow = tt.window
+		cfg.SinglePeriodPercent = 50 // 只检查模式1
+
+		var got *models.Signal
+		for _, s := range DetectOpenInterestSignal(ois, nil, cfg) {
+			if s.Meta["change_periods"] != nil {
+				got = s
+			}
+		}
+		if tt.wantPeriods == 0 {
+			if got != nil {
+				t.Errorf("window %d: got %q, want no signal", tt.window, got.Description)
+			}
+			continue
+		}
+		if got == nil {
+			t.Errorf("window %d: no change signal", tt.window)
+			continue
+		}
+		if got.Meta["change_periods"] != tt.wantPeriods || got.Meta["change_percent"] != tt.wantChange {
+			t.Errorf("window %d: meta = %v, want %d periods and %.0f%%", tt.window, got.Meta, tt.wantPeriods, tt.wantChange)
+		}
+		if want := strconv.Itoa(tt.wantPeriods) + "个周期"; !strings.HasPrefix(got.Description, want) {
+			t.Errorf("window %d: description %q, want it to start with %q", tt.window, got.Description, want)
+		}
+	}
+}
+
+func TestDetectOpenInterestSignalConsecutiveScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []float64
+		wantRun   int // 0 表示不应触发
+		wantScore float64
+	}{
+		// 连续 4 个周期各涨 1%, 累计约 4.06% 相对单周期阈值 3.5%
+		{name: "minimum run", values: []float64{100, 100, 101, 102.01, 103.0301, 104.060401}, wantRun: 4, wantScore: 4.060401 / 3.5},
+		// 连续 8 个周期小幅上涨, 连续周期数是 N 的 2 倍
+		{name: "long run", values: []float64{100, 100.1, 100.2, 100.3, 100.4, 100.5, 100.6, 100.7, 100.8}, wantRun: 8, wantScore: 2},
+		// 连续 4 个周期累计下跌 14%, 是单周期阈值的 4 倍
+		{name: "large fall", values: []float64{100, 100, 97, 93, 90, 86}, wantRun: 4, wantScore: 4},
+		{name: "broken run", values: []float64{100, 101, 102, 101, 102, 103}, wantRun: 0},
+		{name: "flat period breaks run", values: []float64{100, 101, 102, 102, 103, 104}, wantRun: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ois := make([]models.BinanceOI, len(tt.values))
+			for i, v := range tt.values {
+				ois[i] = models.BinanceOI{SumOpenInterest: strconv.FormatFloat(v, 'f', -1, 64), Timestamp: int64(i+1) * 900000}
+			}
+			cfg := DefaultConfig().Detectors.OpenInterest
+			cfg.ChangePercent = 1000 // 只检查模式2
+
+			var got *models.Signal
+			for _, s := range DetectOpenInterestSignal(ois, nil, cfg) {
+				if s.Meta["consecutive_periods"] != nil {
+					got = s
+				}
+			}
+			if tt.wantRun == 0 {
+				if got != nil {
+					t.Fatalf("got %q, want no signal", got.Description)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("no consecutive signal")
+			}
+			if got.Meta["consecutive_periods"] != tt.wantRun {
+				t.Errorf("consecutive_periods = %v, want %d", got.Meta["consecutive_periods"], tt.wantRun)
+			}
+			if math.Abs(got.Score-tt.wantScore) > 1e-9 {
+				t.Errorf("Score = %v, want %v", got.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestDetectOrderBookSignalImbalanceScore(t *testing.T) {
+	tests := []struct {
+		bid, ask  float64
+		wantScore float64 // 0 表示不应触发
+	}{
+		{bid: 70, ask: 30},               // 失衡度 0.4, 低于阈值
+		{bid: 80, ask: 20, wantScore: 1}, // 恰好 4:1
+		{bid: 90, ask: 10, wantScore: 2}, // 失衡度 0.8, 距边界 0.2
+		{bid: 5, ask: 95, wantScore: 4},  // 卖盘方向同样计算
+		{bid: 100, ask: 0, wantScore: maxBoundedStrength},
+	}
+	book := &models.OrderBook{
+		Symbol: "BTCUSDT",
+		Bids:   []models.OrderBookLevel{{Price: 99, Quantity: 1}},
+		Asks:   []models.OrderBookLevel{{Price: 101, Quantity: 1}},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig().Detectors.OrderBook
+		liquidity := []models.DepthLiquidity{{RangePercent: cfg.ImbalanceRange, BidNotional: tt.bid, AskNotional: tt.ask}}
+
+		var got *models.Signal
+		for _, s := range DetectOrderBookSignal(book, liquidity, cfg) {
+			if s.Meta["imbalance"] != nil {
+				got = s
+			}
+		}
+		if tt.wantScore == 0 {
+			if got != nil {
+				t.Errorf("%v:%v: got %q, want no signal", tt.bid, tt.ask, got.Description)
+			}
+			continue
+		}
+		if got == nil {
+			t.Errorf("%v:%v: no imbalance signal", tt.bid, tt.ask)
+			continue
+		}
+		if math.Abs(got.Score-tt.wantScore) > 1e-9 {
+			t.Errorf("%v:%v: Score = %v, want %v", tt.bid, tt.ask, got.Score, tt.wantScore)
+		}
+	}
+}
+
+func TestDetectVolumeSignalLogScaleLabel(t *testing.T) {
+	var klines []models.KlineData
+	for i := 0; i < 20; i++ {
+		klines = append(klines, models.KlineData{Timestamp: int64(i+1) * 900000, Volume: float64(99 + 2*(i%2))})
+	}
+	klines = append(klines, models.KlineData{Timestamp: 21 * 900000, Volume: 1000, Close: 1})
+
+	for _, logScale := range []bool{false, true} {
+		cfg := DefaultConfig().Detectors.Volume
+		cfg.LogScale = logScale
+		s := DetectVolumeSignal(klines, cfg)
+		if s == nil {
+			t.Fatalf("log_scale %v: no signal", logScale)
+		}
+		// 描述应说明分数是否基于对数成交量
+		if got := strings.HasPrefix(s.Description, "对数成交量 "); got != logScale {
+			t.Errorf("log_scale %v: description %q", logScale, s.Description)
+		}
+		if s.Meta["log_scale"] != logScale {
+			t.Errorf("log_scale %v: Meta[log_scale] = %v", logScale, s.Meta["log_scale"])
+		}
+	}
+}
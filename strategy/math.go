@@ -1,8 +1,8 @@
-
 package strategy
 
 import (
 	"math"
+	"sort"
 )
 
 // CalculateMean 计算平均值
@@ -17,6 +17,20 @@ func CalculateMean(data []float64) float64 {
 	return sum / float64(len(data))
 }
 
+// CalculateMedian 计算中位数
+func CalculateMedian(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
 // CalculateStandardDeviation 计算标准差
 func CalculateStandardDeviation(data []float64) float64 {
 	if len(data) < 2 {
@@ -94,3 +108,146 @@ func CalculateRSI(data []float64, period int) float64 {
 	rs := 100 - (100 / (1 + (avgGain / avgLoss)))
 	return rs
 }
+
+// CalculateMAD 计算中位数绝对偏差 (Median Absolute Deviation)
+func CalculateMAD(data []float64) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	median := CalculateMedian(data)
+	deviations := make([]float64, len(data))
+	for i, v := range data {
+		deviations[i] = math.Abs(v - median)
+	}
+	return CalculateMedian(deviations)
+}
+
+// CalculateModifiedZScore 计算 value 相对 baseline 的修正 Z-Score (Iglewicz-Hoaglin)
+// 即 0.6745 * (value - 中位数) / MAD, 对正态分布与普通 Z-Score 可比, 但不受离群值影响。
+// MAD 为 0 (超过一半数据相同) 时改用平均绝对偏差, 两者都为 0 时返回 0。
+func CalculateModifiedZScore(baseline []float64, value float64) float64 {
+	if len(baseline) == 0 {
+		return 0
+	}
+	median := CalculateMedian(baseline)
+	if mad := CalculateMAD(baseline); mad > 0 {
+		return 0.6745 * (value - median) / mad
+	}
+	meanAD := 0.0
+	for _, v := range baseline {
+		meanAD += math.Abs(v - median)
+	}
+	meanAD /= float64(len(baseline))
+	if meanAD == 0 {
+		return 0
+	}
+	return (value - median) / (1.253314 * meanAD)
+}
+
+// Winsorize 将两侧各 fraction 比例的极端值截断为对应分位数处的值, 返回新切片
+func Winsorize(data []float64, fraction float64) []float64 {
+	out := append([]float64(nil), data...)
+	k := trimCount(len(data), fraction)
+	if k == 0 {
+		return out
+	}
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+	low, high := sorted[k], sorted[len(sorted)-1-k]
+	for i, v := range out {
+		out[i] = math.Min(math.Max(v, low), high)
+	}
+	return out
+}
+
+// Trim 去掉两侧各 fraction 比例的极端值, 返回排序后的剩余数据
+func Trim(data []float64, fraction float64) []float64 {
+	sorted := append([]float64(nil), data...)
+	sort.Float64s(sorted)
+	k := trimCount(len(data), fraction)
+	return sorted[k : len(sorted)-k]
+}
+
+// trimCount 返回 Winsorize 与 Trim 在每侧处理的数据点数, 处理后不剩数据时为 0
+func trimCount(n int, fraction float64) int {
+	k := int(fraction * float64(n))
+	if k <= 0 || 2*k >= n {
+		return 0
+	}
+	return k
+}
+
+// truncatedNormalVariance 返回标准正态分布两侧各截去 alpha 比例后的方差,
+// winsorized 为 true 时返回两侧各 alpha 比例缩尾到分位数处后的方差。
+// 截尾/缩尾后的标准差除以其平方根, 对正态数据才是原分布标准差的一致估计。
+func truncatedNormalVariance(alpha float64, winsorized bool) float64 {
+	if alpha <= 0 {
+		return 1
+	}
+	z := math.Sqrt2 * math.Erfinv(1-2*alpha) // 1-alpha 分位数
+	pdf := math.Exp(-z*z/2) / math.Sqrt(2*math.Pi)
+	if winsorized {
+		return 1 - 2*alpha - 2*z*pdf + 2*alpha*z*z
+	}
+	return 1 - 2*z*pdf/(1-2*alpha)
+}
+
+// LogScale 返回 log(1 + v), 用于压缩成交量等右偏的非负序列; 负值按 0 处理
+func LogScale(data []float64) []float64 {
+	out := make([]float64, len(data))
+	for i, v := range data {
+		out[i] = math.Log1p(math.Max(v, 0))
+	}
+	return out
+}
+
+// Estimator 是计算异常分数时使用的统计量
+type Estimator string
+
+const (
+	// EstimatorZScore 使用均值与标准差 (即 CalculateZScore)
+	EstimatorZScore Estimator = "zscore"
+	// EstimatorMAD 使用中位数与 MAD 的修正 Z-Score
+	EstimatorMAD Estimator = "mad"
+	// EstimatorWinsorized 使用缩尾后基线的均值与标准差
+	EstimatorWinsorized Estimator = "winsorized"
+	// EstimatorTrimmed 使用截尾后基线的均值与标准差
+	EstimatorTrimmed Estimator = "trimmed"
+)
+
+// CalculateScore 按 cfg 计算最后一个数据点相对基线的异常分数
+// 默认配置 (zscore, 基线包含最后一个点) 与 CalculateZScore 相同。winsorized 与
+// trimmed 的标准差按实际处理的比例乘以正态分布下的一致性因子, 使分数与 zscore 可比。
+// 基线少于 2 个数据点或离散度为 0 时返回 0。
+func CalculateScore(data []float64, cfg StatsConfig) float64 {
+	if len(data) < 2 {
+		return 0
+	}
+	value := data[len(data)-1]
+	baseline := data
+	if cfg.ExcludeLast {
+		baseline = data[:len(data)-1]
+	}
+	if len(baseline) < 2 {
+		return 0
+	}
+
+	variance := 1.0 // 标准正态分布下处理后基线的方差
+	switch cfg.Estimator {
+	case EstimatorMAD:
+		return CalculateModifiedZScore(baseline, value)
+	case EstimatorWinsorized:
+		alpha := float64(trimCount(len(baseline), cfg.TrimFraction)) / float64(len(baseline))
+		variance = truncatedNormalVariance(alpha, true)
+		baseline = Winsorize(baseline, cfg.TrimFraction)
+	case EstimatorTrimmed:
+		alpha := float64(trimCount(len(baseline), cfg.TrimFraction)) / float64(len(baseline))
+		variance = truncatedNormalVariance(alpha, false)
+		baseline = Trim(baseline, cfg.TrimFraction)
+	}
+	stdDev := CalculateStandardDeviation(baseline) / math.Sqrt(variance)
+	if stdDev == 0 {
+		return 0
+	}
+	return (value - CalculateMean(baseline)) / stdDev
+}
@@ -0,0 +1,124 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+)
+
+// floatsEqual 比较两个切片的长度与各元素, nil 与空切片视为相同
+func floatsEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCalculateMAD(t *testing.T) {
+	tests := []struct {
+		name string
+		data []float64
+		want float64
+	}{
+		{name: "empty", data: nil, want: 0},
+		{name: "single", data: []float64{7}, want: 0},
+		{name: "odd with outlier", data: []float64{1, 2, 3, 4, 100}, want: 1},
+		{name: "even", data: []float64{4, 1, 3, 2}, want: 1},
+		// 超过一半数据相同时 MAD 为 0
+		{name: "mostly equal", data: []float64{5, 5, 5, 1, 100}, want: 0},
+	}
+	for _, tt := range tests {
+		if got := CalculateMAD(tt.data); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: CalculateMAD(%v) = %v, want %v", tt.name, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateModifiedZScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline []float64
+		value    float64
+		want     float64
+	}{
+		{name: "empty", baseline: nil, value: 10, want: 0},
+		// 中位数 3, MAD 1
+		{name: "mad", baseline: []float64{1, 2, 3, 4, 100}, value: 10, want: 0.6745 * 7},
+		{name: "below median", baseline: []float64{1, 2, 3, 4, 100}, value: 1, want: -0.6745 * 2},
+		// MAD 为 0 时改用平均绝对偏差: 中位数 5, 平均绝对偏差 1
+		{name: "mad zero fallback", baseline: []float64{5, 5, 5, 5, 10}, value: 7, want: 2 / 1.253314},
+		{name: "all equal", baseline: []float64{5, 5, 5}, value: 9, want: 0},
+	}
+	for _, tt := range tests {
+		if got := CalculateModifiedZScore(tt.baseline, tt.value); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: CalculateModifiedZScore(%v, %v) = %v, want %v", tt.name, tt.baseline, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWinsorizeAndTrim(t *testing.T) {
+	data := []float64{10, 1, 5, 3, 100, 2, 4, 6, 7, 8}
+	tests := []struct {
+		name      string
+		data      []float64
+		fraction  float64
+		winsorize []float64
+		trim      []float64
+	}{
+		{name: "empty", data: nil, fraction: 0.1, winsorize: nil, trim: nil},
+		// k = 1, 两侧各处理一个点
+		{name: "one per side", data: data, fraction: 0.1,
+			winsorize: []float64{10, 2, 5, 3, 10, 2, 4, 6, 7, 8},
+			trim:      []float64{2, 3, 4, 5, 6, 7, 8, 10}},
+		// k = 0: 5 个点的 10% 不足一个点, 原样返回 (Trim 返回排序后的数据)
+		{name: "k zero", data: []float64{3, 1, 2, 9, 4}, fraction: 0.1,
+			winsorize: []float64{3, 1, 2, 9, 4},
+			trim:      []float64{1, 2, 3, 4, 9}},
+		{name: "zero fraction", data: data, fraction: 0,
+			winsorize: data,
+			trim:      []float64{1, 2, 3, 4, 5, 6, 7, 8, 10, 100}},
+		// 2k >= n: 处理后不剩数据, 原样返回
+		{name: "2k equals n", data: []float64{4, 3, 2, 1}, fraction: 0.5,
+			winsorize: []float64{4, 3, 2, 1},
+			trim:      []float64{1, 2, 3, 4}},
+		{name: "2k above n", data: []float64{3, 1, 2}, fraction: 0.9,
+			winsorize: []float64{3, 1, 2},
+			trim:      []float64{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		input := append([]float64(nil), tt.data...)
+		if got := Winsorize(tt.data, tt.fraction); !floatsEqual(got, tt.winsorize) {
+			t.Errorf("%s: Winsorize = %v, want %v", tt.name, got, tt.winsorize)
+		}
+		if got := Trim(tt.data, tt.fraction); !floatsEqual(got, tt.trim) {
+			t.Errorf("%s: Trim = %v, want %v", tt.name, got, tt.trim)
+		}
+		if !floatsEqual(tt.data, input) {
+			t.Errorf("%s: input modified to %v", tt.name, tt.data)
+		}
+	}
+}
+
+func TestCalculateScoreConsistency(t *testing.T) {
+	// 基线为标准正态分布的分位数, 缩尾与截尾乘以一致性因子后应与 zscore 接近
+	const n = 1000
+	data := make([]float64, n+1)
+	for i := 0; i < n; i++ {
+		data[i] = math.Sqrt2 * math.Erfinv(2*(float64(i)+0.5)/n-1)
+	}
+	data[n] = 3
+
+	want := CalculateScore(data, StatsConfig{Estimator: EstimatorZScore, ExcludeLast: true})
+	for _, estimator := range []Estimator{EstimatorWinsorized, EstimatorTrimmed} {
+		for _, fraction := range []float64{0.05, 0.1, 0.25} {
+			cfg := StatsConfig{Estimator: estimator, ExcludeLast: true, TrimFraction: fraction}
+			if got := CalculateScore(data, cfg); math.Abs(got-want) > 0.02*want {
+				t.Errorf("%s %.2f: score = %.4f, want about %.4f", estimator, fraction, got, want)
+			}
+		}
+	}
+}
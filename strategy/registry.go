@@ -0,0 +1,311 @@
+package strategy
+
+import (
+	"binance-monitor/models"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Requirement 表示检测器所需的一类市场数据
+type Requirement string
+
+const (
+	RequireKlines       Requirement = "klines"
+	RequireOpenInterest Requirement = "open_interest"
+	RequireLSRatios     Requirement = "long_short_ratio"
+	RequireTakerRatios  Requirement = "taker_ratio"
+	// RequireTopRatios 只要求按账户数或按持仓量的大户多空比之一
+	RequireTopRatios    Requirement = "top_trader_ratio"
+	RequireFunding      Requirement = "funding"
+	RequireLiquidations Requirement = "liquidations"
+	RequireDepth        Requirement = "depth"
+	RequireBasis        Requirement = "basis"
+	RequireSpotKlines   Requirement = "spot_klines"
+)
+
+// Available 报告 data 是否包含该类数据
+func (r Requirement) Available(data MarketData) bool {
+	switch r {
+	case RequireKlines:
+		return len(data.Klines) > 0
+	case RequireOpenInterest:
+		return len(data.OIs) > 0
+	case RequireLSRatios:
+		return len(data.LSRatios) > 0
+	case RequireTakerRatios:
+		return len(data.TakerRatios) > 0
+	case RequireTopRatios:
+		return len(data.TopPositionRatios) > 0 || len(data.TopAccountRatios) > 0
+	case RequireFunding:
+		return len(data.FundingRates) > 0 || data.PremiumIndex != nil
+	case RequireLiquidations:
+		return len(data.Liquidations) > 0
+	case RequireDepth:
+		return data.Depth != nil
+	case RequireBasis:
+		return len(data.Basis) > 0
+	case RequireSpotKlines:
+		return len(data.SpotKlines) > 0
+	}
+	return false
+}
+
+// Detector 是一个信号检测器
+//
+// Name 在注册表中唯一, 用于配置启用/禁用; Requires 声明所需的数据, 缺少任一
+// 数据时检测器不会被调用; Detect 返回检测到的信号 (没有时为空)。
+type Detector interface {
+	Name() string
+	Requires() []Requirement
+	Detect(data MarketData) []models.Signal
+}
+
+// NewDetector 由检测函数创建 Detector
+func NewDetector(name string, requires []Requirement, detect func(data MarketData) []models.Signal) Detector {
+	return funcDetector{name: name, requires: requires, detect: detect}
+}
+
+type funcDetector struct {
+	name     string
+	requires []Requirement
+	detect   func(data MarketData) []models.Signal
+}
+
+func (d funcDetector) Name() string                           { return d.name }
+func (d funcDetector) Requires() []Requirement                { return d.requires }
+func (d funcDetector) Detect(data MarketData) []models.Signal { return d.detect(data) }
+
+// Registry 按注册顺序保存检测器及其按交易对的启用状态, 可并发使用
+type Registry struct {
+	mu        sync.RWMutex
+	detectors []Detector
+	// disabled[name][symbol] 为 true 表示禁用, symbol 为 "" 表示所有交易对
+	disabled map[string]map[string]bool
+}
+
+// NewRegistry 创建一个空的注册表
+func NewRegistry() *Registry {
+	return &Registry{disabled: map[string]map[string]bool{}}
+}
+
+// DefaultRegistry 是 Analyze 使用的注册表, 包含所有内置检测器
+var DefaultRegistry = NewRegistry()
+
+// Register 向 DefaultRegistry 注册检测器, 名称重复时 panic
+// 供自定义检测器包在 init 中调用。
+func Register(d Detector) {
+	if err := DefaultRegistry.Register(d); err != nil {
+		panic(err)
+	}
+}
+
+// Register 注册检测器, 新检测器默认对所有交易对启用
+func (r *Registry) Register(d Detector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if d.Name() == "" {
+		return fmt.Errorf("detector name is empty")
+	}
+	for _, existing := range r.detectors {
+		if existing.Name() == d.Name() {
+			return fmt.Errorf("detector %q is already registered", d.Name())
+		}
+	}
+	r.detectors = append(r.detectors, d)
+	return nil
+}
+
+// Detectors 返回已注册的检测器, 按注册顺序
+func (r *Registry) Detectors() []Detector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Detector(nil), r.detectors...)
+}
+
+// SetEnabled 启用或禁用检测器; symbol 为 "" 时作用于所有交易对,
+// 否则只作用于该交易对并优先于全局设置。
+func (r *Registry) SetEnabled(name, symbol string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.registeredLocked(name) {
+		return fmt.Errorf("unknown detector %q", name)
+	}
+	if symbol == "" || r.disabled[name] == nil {
+		// 全局设置覆盖此前的按交易对设置
+		r.disabled[name] = map[string]bool{"": r.disabled[name][""]}
+	}
+	r.disabled[name][strings.ToUpper(symbol)] = !enabled
+	return nil
+}
+
+// Enabled 报告检测器是否对 symbol 启用
+func (r *Registry) Enabled(name, symbol string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rules := r.disabled[name]
+	if disabled, ok := rules[strings.ToUpper(symbol)]; ok {
+		return !disabled
+	}
+	return !rules[""]
+}
+
+func (r *Registry) registered(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.registeredLocked(name)
+}
+
+func (r *Registry) registeredLocked(name string) bool {
+	for _, d := range r.detectors {
+		if d.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Configure 按顺序应用以逗号分隔的启用规则, 例如
+//
+//	"-orderbook,-basis,basis@BTCUSDT"
+//
+// 表示禁用 orderbook, 并只对 BTCUSDT 启用 basis。规则格式为 [-]name[@SYMBOL],
+// 前缀 "-" 表示禁用; name 为 "*" 时作用于所有已注册的检测器。
+func (r *Registry) Configure(rules string) error {
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		enabled := !strings.HasPrefix(rule, "-")
+		rule = strings.TrimPrefix(rule, "-")
+		name, symbol := rule, ""
+		if i := strings.Index(rule, "@"); i >= 0 {
+			name, symbol = rule[:i], rule[i+1:]
+			if symbol == "" {
+				return fmt.Errorf("invalid detector rule %q: empty symbol", rule)
+			}
+		}
+
+		names := []string{name}
+		if name == "*" {
+			names = nil
+			for _, d := range r.Detectors() {
+				names = append(names, d.Name())
+			}
+		}
+		for _, n := range names {
+			if err := r.SetEnabled(n, symbol, enabled); err != nil {
+				return fmt.Errorf("invalid detector rule %q: %w", rule, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Detect 依次运行对 data.Symbol 启用且所需数据齐全的检测器, 返回所有信号
+// 每个信号的 Meta["detector"] 记录产生它的检测器名称; 检测器未设置的 Severity
+// 按 Score 与 ActiveConfig 中的 severity 阈值确定, 未设置的 Direction 视为中性。
+func (r *Registry) Detect(data MarketData) []models.Signal {
+	severity := ActiveConfig().For(data.Symbol).Severity
+	var signals []models.Signal
+	for _, d := range r.Detectors() {
+		if !r.Enabled(d.Name(), data.Symbol) || !available(d, data) {
+			continue
+		}
+		for _, s := range d.Detect(data) {
+			if s.Meta == nil {
+				s.Meta = map[string]interface{}{}
+			}
+			s.Meta["detector"] = d.Name()
+			if s.Severity == "" {
+				s.Severity = severity.For(s.Score)
+			}
+			if s.Direction == "" {
+				s.Direction = models.Neutral
+			}
+			signals = append(signals, s)
+		}
+	}
+	return signals
+}
+
+func available(d Detector, data MarketData) bool {
+	for _, req := range d.Requires() {
+		if !req.Available(data) {
+			return false
+		}
+	}
+	return true
+}
+
+// one 将可能为 nil 的单个信号转换为信号列表, 并在 Meta["params"] 中记录生效的参数
+func one(s *models.Signal, params interface{}) []models.Signal {
+	if s == nil {
+		return nil
+	}
+	return all([]*models.Signal{s}, params)
+}
+
+// all 将信号指针列表转换为信号列表, 并在 Meta["params"] 中记录生效的参数
+func all(signals []*models.Signal, params interface{}) []models.Signal {
+	out := make([]models.Signal, 0, len(signals))
+	for _, s := range signals {
+		if s.Meta == nil {
+			s.Meta = map[string]interface{}{}
+		}
+		s.Meta["params"] = params
+		out = append(out, *s)
+	}
+	return out
+}
+
+// 内置检测器, 按原 Analyze 的检测顺序注册; 参数取自 ActiveConfig 中该交易对生效的配置
+func init() {
+	Register(NewDetector("volume", []Requirement{RequireKlines}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).Volume
+		return one(DetectVolumeSignal(data.Klines, cfg), cfg)
+	}))
+	Register(NewDetector("open_interest", []Requirement{RequireOpenInterest}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).OpenInterest
+		return all(DetectOpenInterestSignal(data.OIs, data.Klines, cfg), cfg)
+	}))
+	Register(NewDetector("long_short_ratio", []Requirement{RequireLSRatios}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).LSRatio
+		return one(DetectLSRatioSignal(data.LSRatios, cfg), cfg)
+	}))
+	// 没有主动买卖比序列时退回K线中的主动买入量
+	Register(NewDetector("taker_imbalance", []Requirement{RequireKlines}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).TakerImbalance
+		return one(DetectTakerImbalanceSignal(data.Symbol, data.TakerRatios, data.Klines, cfg), cfg)
+	}))
+	// 优先使用按持仓量统计的大户多空比
+	Register(NewDetector("smart_money_divergence", []Requirement{RequireTopRatios, RequireLSRatios}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).SmartMoneyDivergence
+		topRatios, topBasis := data.TopPositionRatios, "position"
+		if len(topRatios) == 0 {
+			topRatios, topBasis = data.TopAccountRatios, "account"
+		}
+		return one(DetectSmartMoneyDivergenceSignal(topRatios, topBasis, data.LSRatios, cfg), cfg)
+	}))
+	Register(NewDetector("funding", []Requirement{RequireFunding}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).Funding
+		return all(DetectFundingSignal(data.FundingRates, data.PremiumIndex, cfg), cfg)
+	}))
+	Register(NewDetector("liquidation_cascade", []Requirement{RequireLiquidations, RequireKlines}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).LiquidationCascade
+		return one(DetectLiquidationCascadeSignal(data.Liquidations, data.Klines, cfg), cfg)
+	}))
+	Register(NewDetector("orderbook", []Requirement{RequireDepth}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).OrderBook
+		return all(DetectOrderBookSignal(data.Depth, data.DepthLiquidity, cfg), cfg)
+	}))
+	Register(NewDetector("basis", []Requirement{RequireBasis}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).Basis
+		return all(DetectBasisSignal(data.Symbol, data.Basis, cfg), cfg)
+	}))
+	Register(NewDetector("spot_perp_volume", []Requirement{RequireKlines, RequireSpotKlines}, func(data MarketData) []models.Signal {
+		cfg := ActiveConfig().For(data.Symbol).SpotPerpVolume
+		return one(DetectSpotPerpVolumeSignal(data.Klines, data.SpotKlines, cfg), cfg)
+	}))
+}
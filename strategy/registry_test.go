@@ -0,0 +1,71 @@
+package strategy
+
+import (
+	"strings"
+	"testing"
+)
+
+// testRegistry 创建包含 volume、basis 与 orderbook 三个空检测器的注册表
+func testRegistry(t *testing.T) *Registry {
+	t.Helper()
+	r := NewRegistry()
+	for _, name := range []string{"volume", "basis", "orderbook"} {
+		if err := r.Register(NewDetector(name, nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func TestRegistryConfigure(t *testing.T) {
+	// disabled 列出应被禁用的 "检测器@交易对" 组合, 其余组合应保持启用
+	tests := []struct {
+		name     string
+		rules    string
+		disabled []string
+		wantErr  string
+	}{
+		{name: "empty", rules: ""},
+		{name: "disable globally", rules: "-orderbook", disabled: []string{"orderbook@BTCUSDT", "orderbook@ETHUSDT"}},
+		{name: "whitespace and empty rules", rules: " -orderbook , ,", disabled: []string{"orderbook@BTCUSDT", "orderbook@ETHUSDT"}},
+		{name: "enable one symbol only", rules: "-basis,basis@BTCUSDT", disabled: []string{"basis@ETHUSDT"}},
+		{name: "symbol is case-insensitive", rules: "-basis,basis@btcusdt", disabled: []string{"basis@ETHUSDT"}},
+		{name: "disable one symbol", rules: "-volume@ETHUSDT", disabled: []string{"volume@ETHUSDT"}},
+		// 全局规则覆盖此前的按交易对规则
+		{name: "global rule overrides symbol rule", rules: "basis@BTCUSDT,-basis", disabled: []string{"basis@BTCUSDT", "basis@ETHUSDT"}},
+		{name: "later rule wins", rules: "-basis,basis"},
+		{name: "wildcard", rules: "-*,volume", disabled: []string{"basis@BTCUSDT", "basis@ETHUSDT", "orderbook@BTCUSDT", "orderbook@ETHUSDT"}},
+		{name: "wildcard per symbol", rules: "-*@ETHUSDT", disabled: []string{"volume@ETHUSDT", "basis@ETHUSDT", "orderbook@ETHUSDT"}},
+		{name: "unknown detector", rules: "-orderbok", wantErr: `unknown detector "orderbok"`},
+		{name: "unknown detector per symbol", rules: "nope@BTCUSDT", wantErr: `unknown detector "nope"`},
+		{name: "empty symbol", rules: "basis@", wantErr: "empty symbol"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testRegistry(t)
+			err := r.Configure(tt.rules)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Configure(%q) error = %v, want it to contain %q", tt.rules, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Configure(%q): %v", tt.rules, err)
+			}
+
+			disabled := map[string]bool{}
+			for _, key := range tt.disabled {
+				disabled[key] = true
+			}
+			for _, name := range []string{"volume", "basis", "orderbook"} {
+				for _, symbol := range []string{"BTCUSDT", "ETHUSDT"} {
+					key := name + "@" + symbol
+					if got := r.Enabled(name, symbol); got == disabled[key] {
+						t.Errorf("Enabled(%s) = %v, want %v", key, got, !disabled[key])
+					}
+				}
+			}
+		})
+	}
+}
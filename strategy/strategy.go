@@ -1,44 +1,58 @@
-
 package strategy
 
 import (
 	"binance-monitor/models"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"sort"
 	"strconv"
 	"strings"
-	"io"
+	"sync"
+	"time"
 )
 
 // MarketData 包含用于分析的所有市场数据
+//
+// Klines 只包含已收盘K线, 统计序列与之对齐到相同的开盘时间网格 (见 AlignMarketData)。
 type MarketData struct {
 	Symbol   string
+	Exchange string
 	Klines   []models.KlineData
-	OIs      []models.BinanceOI
-	LSRatios []models.GlobalLongShortRatio
+	// LiveKline 是仍在形成中的最新K线, 不参与检测
+	LiveKline   *models.KlineData
+	OIs         []models.BinanceOI
+	LSRatios    []models.GlobalLongShortRatio
+	TakerRatios []models.TakerLongShortRatio
+	// 大户多空比 (按账户数 / 按持仓量)
+	TopAccountRatios  []models.TopLongShortRatio
+	TopPositionRatios []models.TopLongShortRatio
+	// 历史资金费率与最新溢价指数 (含预测资金费率)
+	FundingRates []models.FundingRate
+	PremiumIndex *models.PremiumIndex
+	// 按K线周期聚合的强平数据, 与 Klines 一一对应。币安已不再提供历史强平订单的 REST 接口,
+	// 只有流式模式 (stream 包) 会根据 forceOrder 推送填充
+	Liquidations []models.LiquidationData
+	// 订单簿快照及中间价附近 (DepthRanges) 的累计流动性
+	Depth          *models.OrderBook
+	DepthLiquidity []models.DepthLiquidity
+	// 永续合约与交割合约相对现货指数的滚动基差
+	Basis []models.BasisSeries
+	// 同一币种现货市场的K线, 用于比较期现成交量
+	SpotKlines []models.KlineData
+
+	// Warnings 记录获取失败的可选数据序列, 对应的检测器会被跳过
+	Warnings []string
 }
 
 // Analyze 是策略分析的主入口函数
+// 依次运行 DefaultRegistry 中对该交易对启用的检测器 (见 Registry.Detect),
+// 再将同时触发的信号合并为复合信号 (见 DetectComposite)。
 func Analyze(data MarketData) []models.Signal {
-	var signals []models.Signal
+	signals := DefaultRegistry.Detect(data)
+	signals = DetectComposite(data.Symbol, signals, ActiveConfig().For(data.Symbol).Composite)
 
-	// 1. 检测成交量异常信号
-	if volSignal := DetectVolumeSignal(data.Klines); volSignal != nil {
-		signals = append(signals, *volSignal)
-	}
-
-	// 2. 检测持仓量异动信号
-	oiSignals := DetectOpenInterestSignal(data.OIs)
-	if len(oiSignals) > 0 {
-		for _, s := range oiSignals {
-			signals = append(signals, *s)
-		}
-	}
-
-	// 3. 检测多空比极端信号
-	if lsRatioSignal := DetectLSRatioSignal(data.LSRatios); lsRatioSignal != nil {
-		signals = append(signals, *lsRatioSignal)
+	for i := range signals {
+		signals[i].Exchange = data.Exchange
 	}
 
 	return signals
@@ -57,143 +71,613 @@ func BuildContextData(data MarketData) string {
 	ema12 := CalculateEMA(closePrices, 12)
 	ema26 := CalculateEMA(closePrices, 26)
 
-	sb.WriteString(fmt.Sprintf("### 关键指标摘要
-"))
-	sb.WriteString(fmt.Sprintf("- **最新收盘价:** %.4f
-", data.Klines[len(data.Klines)-1].Close))
-	sb.WriteString(fmt.Sprintf("- **RSI (14):** %.2f
-", rsi14))
-	sb.WriteString(fmt.Sprintf("- **EMA (12/26):** %.4f / %.4f
-", ema12, ema26))
-	lastOI, _ := strconv.ParseFloat(data.OIs[len(data.OIs)-1].SumOpenInterest, 64)
-	sb.WriteString(fmt.Sprintf("- **最新持仓量 (OI):** %.2f
-", lastOI))
-	lastLSR, _ := strconv.ParseFloat(data.LSRatios[len(data.LSRatios)-1].LongShortRatio, 64)
-	sb.WriteString(fmt.Sprintf("- **最新多空比:** %.4f
-
-", lastLSR))
-
-	sb.WriteString("### 最近K线 (OHLCV)
-")
+	sb.WriteString(fmt.Sprintf("### 关键指标摘要\n"))
+	if len(data.Klines) > 0 {
+		sb.WriteString(fmt.Sprintf("- **最新收盘价:** %.4f\n", data.Klines[len(data.Klines)-1].Close))
+	}
+	sb.WriteString(fmt.Sprintf("- **RSI (14):** %.2f\n", rsi14))
+	sb.WriteString(fmt.Sprintf("- **EMA (12/26):** %.4f / %.4f\n", ema12, ema26))
+	if len(data.OIs) > 0 {
+		lastOI, _ := strconv.ParseFloat(data.OIs[len(data.OIs)-1].SumOpenInterest, 64)
+		sb.WriteString(fmt.Sprintf("- **最新持仓量 (OI):** %.2f\n", lastOI))
+	}
+	if len(data.LSRatios) > 0 {
+		lastLSR, _ := strconv.ParseFloat(data.LSRatios[len(data.LSRatios)-1].LongShortRatio, 64)
+		sb.WriteString(fmt.Sprintf("- **最新多空比:** %.4f\n", lastLSR))
+	}
+	if len(data.TakerRatios) > 0 {
+		lastTaker := data.TakerRatios[len(data.TakerRatios)-1]
+		buySell, _ := strconv.ParseFloat(lastTaker.BuySellRatio, 64)
+		buyVol, _ := strconv.ParseFloat(lastTaker.BuyVol, 64)
+		sellVol, _ := strconv.ParseFloat(lastTaker.SellVol, 64)
+		sb.WriteString(fmt.Sprintf("- **主动买卖比 (Taker Buy/Sell):** %.4f (买 %.2f / 卖 %.2f)\n", buySell, buyVol, sellVol))
+	}
+	if len(data.TopAccountRatios) > 0 {
+		lastTop, _ := strconv.ParseFloat(data.TopAccountRatios[len(data.TopAccountRatios)-1].LongShortRatio, 64)
+		sb.WriteString(fmt.Sprintf("- **大户多空比 (账户数):** %.4f\n", lastTop))
+	}
+	if len(data.TopPositionRatios) > 0 {
+		lastTop, _ := strconv.ParseFloat(data.TopPositionRatios[len(data.TopPositionRatios)-1].LongShortRatio, 64)
+		sb.WriteString(fmt.Sprintf("- **大户多空比 (持仓量):** %.4f\n", lastTop))
+	}
+	if len(data.FundingRates) > 0 {
+		lastFunding, _ := strconv.ParseFloat(data.FundingRates[len(data.FundingRates)-1].FundingRate, 64)
+		sb.WriteString(fmt.Sprintf("- **上期资金费率:** %.4f%%\n", lastFunding*100))
+	}
+	if data.PremiumIndex != nil {
+		predicted, _ := strconv.ParseFloat(data.PremiumIndex.LastFundingRate, 64)
+		markPrice, _ := strconv.ParseFloat(data.PremiumIndex.MarkPrice, 64)
+		indexPrice, _ := strconv.ParseFloat(data.PremiumIndex.IndexPrice, 64)
+		nextFunding := time.Unix(0, data.PremiumIndex.NextFundingTime*int64(time.Millisecond)).UTC()
+		sb.WriteString(fmt.Sprintf("- **预测资金费率:** %.4f%% (下次结算: %s UTC)\n", predicted*100, nextFunding.Format("2006-01-02 15:04")))
+		sb.WriteString(fmt.Sprintf("- **标记价格 / 指数价格:** %.4f / %.4f\n", markPrice, indexPrice))
+	}
+	if n := len(data.Liquidations); n > 0 && !data.Liquidations[n-1].Unknown {
+		lastLiq := data.Liquidations[n-1]
+		sb.WriteString(fmt.Sprintf("- **本周期强平 (多/空):** %.2f / %.2f USDT\n", lastLiq.LongNotional, lastLiq.ShortNotional))
+	}
+	if len(data.DepthLiquidity) > 0 {
+		sb.WriteString("- **盘口累计流动性 (买盘 / 卖盘, USDT):**\n")
+		for _, l := range data.DepthLiquidity {
+			sb.WriteString(fmt.Sprintf("  - ±%.1f%%: %.0f / %.0f\n", l.RangePercent, l.BidNotional, l.AskNotional))
+		}
+	}
+	for _, b := range data.Basis {
+		if len(b.Points) == 0 {
+			continue
+		}
+		lastBasis := b.Points[len(b.Points)-1]
+		sb.WriteString(fmt.Sprintf("- **基差 %s (%s):** %.4f%% (年化 %.2f%%)\n", b.Symbol, b.ContractType, lastBasis.Basis*100, lastBasis.Annualized*100))
+	}
+	if ratios := SpotPerpVolumeRatios(data.Klines, data.SpotKlines); len(ratios) > 0 {
+		last := ratios[len(ratios)-1]
+		sb.WriteString(fmt.Sprintf("- **最新成交额 (合约 / 现货, USDT):** %.0f / %.0f\n", last.PerpVolume, last.SpotVolume))
+		sb.WriteString(fmt.Sprintf("- **期现成交量比:** %.2f (近期中位数 %.2f)\n", last.Ratio, CalculateMedian(ratioValues(ratios))))
+	}
+	if len(data.Klines) > 0 && data.Klines[len(data.Klines)-1].Volume > 0 {
+		lastKline := data.Klines[len(data.Klines)-1]
+		sb.WriteString(fmt.Sprintf("- **最新K线主动买入占比:** %.2f%%\n", lastKline.TakerBuyVolume/lastKline.Volume*100))
+	}
+	if data.LiveKline != nil {
+		k := data.LiveKline
+		sb.WriteString(fmt.Sprintf("- **当前未收盘K线 (不参与信号计算):** O: %.4f, C: %.4f, V: %.2f\n", k.Open, k.Close, k.Volume))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### 最近已收盘K线 (OHLCV)\n")
 	start := len(data.Klines) - 5
 	if start < 0 {
 		start = 0
 	}
 	for i := start; i < len(data.Klines); i++ {
 		k := data.Klines[i]
-		sb.WriteString(fmt.Sprintf("  - T: %d, O: %.2f, H: %.2f, L: %.2f, C: %.2f, V: %.2f
-", k.Timestamp, k.Open, k.High, k.Low, k.Close, k.Volume))
+		sb.WriteString(fmt.Sprintf("  - T: %d, O: %.2f, H: %.2f, L: %.2f, C: %.2f, V: %.2f", k.Timestamp, k.Open, k.High, k.Low, k.Close, k.Volume))
+		if k.TradeCount > 0 {
+			sb.WriteString(fmt.Sprintf(", QV: %.0f, N: %d", k.QuoteVolume, k.TradeCount))
+		}
+		sb.WriteString("\n")
 	}
 
 	return sb.String()
 }
 
-// --- Data Fetching ---
+// --- 数据获取 ---
+
+// MarketDataSource 抽象了行情数据的来源 (交易所、代理、测试桩等)
+//
+// 实现返回的序列按时间从旧到新排列; period 与 interval 使用币安的命名 ("5m", "15m", "1h", ...)。
+type MarketDataSource interface {
+	// Exchange 返回记录在信号上的交易所名称, 例如 "binance"
+	Exchange() string
+	// Klines 返回最近 limit 根K线
+	Klines(symbol, interval string, limit int) ([]models.KlineData, error)
+	// OpenInterest 返回最近 limit 个持仓量快照
+	OpenInterest(symbol, period string, limit int) ([]models.BinanceOI, error)
+	// LongShortRatio 返回最近 limit 个全市场多空账户比
+	LongShortRatio(symbol, period string, limit int) ([]models.GlobalLongShortRatio, error)
+}
+
+// TakerVolumeSource 是可选接口, 由能提供主动买卖量比数据的数据源实现
+type TakerVolumeSource interface {
+	TakerLongShortRatio(symbol, period string, limit int) ([]models.TakerLongShortRatio, error)
+}
+
+// TopTraderSource 是可选接口, 由能提供大户多空比数据的数据源实现
+type TopTraderSource interface {
+	TopLongShortAccountRatio(symbol, period string, limit int) ([]models.TopLongShortRatio, error)
+	TopLongShortPositionRatio(symbol, period string, limit int) ([]models.TopLongShortRatio, error)
+}
+
+// FundingSource 是可选接口, 由能提供资金费率与溢价指数数据的数据源实现
+type FundingSource interface {
+	FundingRates(symbol string, limit int) ([]models.FundingRate, error)
+	PremiumIndex(symbol string) (*models.PremiumIndex, error)
+}
+
+// DepthSource 是可选接口, 由能提供订单簿快照的数据源实现
+type DepthSource interface {
+	Depth(symbol string, limit int) (*models.OrderBook, error)
+}
 
-// FetchMarketData fetches all required market data for a symbol.
-func FetchMarketData(symbol, interval string, limit int) (MarketData, error) {
+// BasisSource 是可选接口, 由能提供现货指数与交割合约价格的数据源实现
+type BasisSource interface {
+	IndexPriceKlines(symbol, interval string, limit int) ([]models.KlineData, error)
+	DeliveryContracts(symbol, interval string, limit int) ([]models.DeliveryContract, error)
+}
+
+// SpotSource 是可选接口, 由能提供同一币种现货K线的数据源实现
+type SpotSource interface {
+	// SpotKlines 返回与永续合约同一币种的现货市场最近 limit 根K线
+	SpotKlines(symbol, interval string, limit int) ([]models.KlineData, error)
+}
+
+// WeightBudget 是可选接口, 由按请求权重计费并设有权重预算的数据源实现 (例如 binance.Client)。
+// 预算不足以覆盖所有序列时, FetchMarketData 会跳过可选序列, 把预算留给必需序列。
+type WeightBudget interface {
+	// RemainingWeight 返回预算中剩余的权重, 未设预算时返回 -1
+	RemainingWeight() int
+	// FetchWeight 返回 FetchMarketData 为一个交易对获取 limit 个数据点时,
+	// 必需序列与可选序列各自消耗的权重
+	FetchWeight(limit int) (required, optional int)
+}
+
+// ErrNotSupported 由包装其他数据源的实现 (例如本地存储) 在底层数据源不支持
+// 某个可选接口时返回, FetchMarketData 会静默跳过对应序列。
+var ErrNotSupported = errors.New("not supported by this source")
+
+// fetchTask 是 FetchMarketData 中的一次接口调用
+type fetchTask struct {
+	name     string
+	required bool
+	run      func() error
+}
+
+// FetchMarketData 从 src 获取一个交易对所需的全部行情数据
+//
+// 各接口并发请求。多个必需序列失败时, 返回声明顺序中第一个的错误。可选序列在 src
+// 实现了对应接口时获取, 其失败记录在 MarketData.Warnings 中而不会使该交易对失败。
+// src 是预算不足以覆盖所有序列的 WeightBudget 时, 跳过可选序列并记录警告。
+// 结果按 opts 经 AlignMarketData 对齐, 所有序列都只包含已收盘周期。
+func FetchMarketData(src MarketDataSource, symbol, interval string, limit int, opts AlignOptions) (MarketData, error) {
+	return fetchMarketData(src, symbol, interval, limit, 0, opts)
+}
+
+// fetchMarketData 是为尚未获取的交易对保留 reserve 权重的 FetchMarketData:
+// 只有预算足以覆盖可选序列、本交易对的必需序列与 reserve 时才获取可选序列。
+func fetchMarketData(src MarketDataSource, symbol, interval string, limit, reserve int, opts AlignOptions) (MarketData, error) {
 	var data MarketData
 	data.Symbol = symbol
+	data.Exchange = src.Exchange()
 
-	klines, err := getKlines(symbol, interval, limit)
+	period, err := ParseInterval(interval)
 	if err != nil {
-		return data, fmt.Errorf("failed to get klines: %w", err)
+		return data, err
+	}
+	// 多取一个周期以保证对齐后仍有 limit 个已收盘周期:
+	// 最新K线通常尚未收盘, 最早的统计点对齐后落在网格之外
+	fetchLimit := limit + 1
+
+	tasks := []fetchTask{
+		{"klines", true, func() (err error) {
+			data.Klines, err = src.Klines(symbol, interval, fetchLimit)
+			return
+		}},
+		{"open interest", true, func() (err error) {
+			data.OIs, err = src.OpenInterest(symbol, interval, fetchLimit)
+			return
+		}},
+		{"long/short ratio", true, func() (err error) {
+			data.LSRatios, err = src.LongShortRatio(symbol, interval, fetchLimit)
+			return
+		}},
+	}
+	if ts, ok := src.(TakerVolumeSource); ok {
+		tasks = append(tasks, fetchTask{"taker buy/sell ratio", false, func() (err error) {
+			data.TakerRatios, err = ts.TakerLongShortRatio(symbol, interval, fetchLimit)
+			return
+		}})
+	}
+	if ts, ok := src.(TopTraderSource); ok {
+		tasks = append(tasks, fetchTask{"top trader account ratio", false, func() (err error) {
+			data.TopAccountRatios, err = ts.TopLongShortAccountRatio(symbol, interval, fetchLimit)
+			return
+		}}, fetchTask{"top trader position ratio", false, func() (err error) {
+			data.TopPositionRatios, err = ts.TopLongShortPositionRatio(symbol, interval, fetchLimit)
+			return
+		}})
+	}
+	if ds, ok := src.(DepthSource); ok {
+		tasks = append(tasks, fetchTask{"order book depth", false, func() (err error) {
+			data.Depth, err = ds.Depth(symbol, DepthLimit)
+			return
+		}})
+	}
+	var indexKlines []models.KlineData
+	var deliveries []models.DeliveryContract
+	if bs, ok := src.(BasisSource); ok {
+		tasks = append(tasks, fetchTask{"index price klines", false, func() (err error) {
+			indexKlines, err = bs.IndexPriceKlines(symbol, interval, fetchLimit)
+			return
+		}}, fetchTask{"delivery contracts", false, func() (err error) {
+			deliveries, err = bs.DeliveryContracts(symbol, interval, fetchLimit)
+			return
+		}})
+	}
+	if ss, ok := src.(SpotSource); ok {
+		tasks = append(tasks, fetchTask{"spot klines", false, func() (err error) {
+			data.SpotKlines, err = ss.SpotKlines(symbol, interval, fetchLimit)
+			return
+		}})
+	}
+	if fs, ok := src.(FundingSource); ok {
+		tasks = append(tasks, fetchTask{"funding rates", false, func() (err error) {
+			data.FundingRates, err = fs.FundingRates(symbol, fetchLimit)
+			return
+		}}, fetchTask{"premium index", false, func() (err error) {
+			data.PremiumIndex, err = fs.PremiumIndex(symbol)
+			return
+		}})
 	}
-	data.Klines = klines
 
-	ois, err := getOpenInterest(symbol, interval, limit)
-	if err != nil {
-		return data, fmt.Errorf("failed to get open interest: %w", err)
+	if wb, ok := src.(WeightBudget); ok {
+		required, optional := wb.FetchWeight(fetchLimit)
+		if remaining := wb.RemainingWeight(); remaining >= 0 && remaining < required+optional+reserve {
+			var kept []fetchTask
+			for _, task := range tasks {
+				if task.required {
+					kept = append(kept, task)
+				}
+			}
+			if len(kept) < len(tasks) {
+				data.Warnings = append(data.Warnings, fmt.Sprintf("skipped %d optional series: remaining weight budget %d", len(tasks)-len(kept), remaining))
+			}
+			tasks = kept
+		}
 	}
-	data.OIs = ois
 
-	lsRatios, err := getGlobalLongShortAccountRatio(symbol, interval, limit)
-	if err != nil {
-		return data, fmt.Errorf("failed to get long/short ratio: %w", err)
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+	for i := range tasks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			errs[i] = tasks[i].run()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, task := range tasks {
+		if errs[i] == nil {
+			continue
+		}
+		if errors.Is(errs[i], ErrNotSupported) {
+			continue
+		}
+		if task.required {
+			return data, fmt.Errorf("failed to get %s: %w", task.name, errs[i])
+		}
+		data.Warnings = append(data.Warnings, fmt.Sprintf("failed to get %s: %v", task.name, errs[i]))
+	}
+
+	if err := AlignMarketData(&data, period, limit, opts); err != nil {
+		return data, err
+	}
+
+	if data.Depth != nil {
+		data.DepthLiquidity = CalculateDepthLiquidity(data.Depth, DepthRanges)
+	}
+	if len(indexKlines) > 0 {
+		data.Basis = CalculateBasis(symbol, data.Klines, indexKlines, deliveries)
 	}
-	data.LSRatios = lsRatios
 
 	return data, nil
 }
 
-func getKlines(symbol, interval string, limit int) ([]models.KlineData, error) {
-	// ... implementation remains the same ...
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/klines?symbol=%s&interval=%s&limit=%d", symbol, interval, limit)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+// DepthLimit 是订单簿每侧获取的档位数。在币安上, 100 档是能覆盖流动性较好的交易对
+// DepthRanges 中 ±1%/±2% 范围的最低权重档位。
+const DepthLimit = 100
+
+// KlineNotional 返回K线的成交额 (计价币种), 数据源未提供时按成交量乘以收盘价估算
+func KlineNotional(k models.KlineData) float64 {
+	if k.QuoteVolume > 0 {
+		return k.QuoteVolume
 	}
-	defer resp.Body.Close()
+	return k.Volume * k.Close
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var rawKlines []models.BinanceKline
-	if err := json.Unmarshal(body, &rawKlines); err != nil {
-		return nil, err
-	}
-
-	var klines []models.KlineData
-	for _, k := range rawKlines {
-		open, _ := strconv.ParseFloat(k[1].(string), 64)
-		high, _ := strconv.ParseFloat(k[2].(string), 64)
-		low, _ := strconv.ParseFloat(k[3].(string), 64)
-		close, _ := strconv.ParseFloat(k[4].(string), 64)
-		volume, _ := strconv.ParseFloat(k[5].(string), 64)
-
-		klines = append(klines, models.KlineData{
-			Symbol:    symbol,
-			Timestamp: int64(k[0].(float64)),
-			Open:      open,
-			High:      high,
-			Low:       low,
-			Close:     close,
-			Volume:    volume,
+// SpotPerpVolumeRatios 按K线开盘时间对齐合约与现货K线, 计算每个周期的期现成交额比
+// 使用计价币种成交额而非成交量, 因为两个市场的成交量单位不一定相同 (合约可能按张计);
+// 任一方缺失或成交额为零的周期会被跳过。1000PEPEUSDT 这类带倍数前缀的合约没有同名现货市场,
+// 现货K线获取失败, 检测器随之跳过。
+func SpotPerpVolumeRatios(perp, spot []models.KlineData) []models.VolumeRatioPoint {
+	spotByTime := make(map[int64]float64, len(spot))
+	for _, k := range spot {
+		spotByTime[k.Timestamp] = KlineNotional(k)
+	}
+
+	var points []models.VolumeRatioPoint
+	for _, k := range perp {
+		spotVolume, ok := spotByTime[k.Timestamp]
+		perpVolume := KlineNotional(k)
+		if !ok || spotVolume <= 0 || perpVolume <= 0 {
+			continue
+		}
+		points = append(points, models.VolumeRatioPoint{
+			Timestamp:  k.Timestamp,
+			PerpVolume: perpVolume,
+			SpotVolume: spotVolume,
+			Ratio:      perpVolume / spotVolume,
 		})
 	}
-	return klines, nil
+	return points
 }
 
-func getOpenInterest(symbol, period string, limit int) ([]models.BinanceOI, error) {
-	// ... implementation remains the same ...
-	url := fmt.Sprintf("https://fapi.binance.com/futures/data/openInterestHist?symbol=%s&period=%s&limit=%d", symbol, period, limit)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+// ratioValues 提取期现成交额比序列中的比值
+func ratioValues(points []models.VolumeRatioPoint) []float64 {
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = p.Ratio
+	}
+	return values
+}
+
+// perpFundingPeriodsPerYear 用于年化永续合约基差: 按每8小时结算一次资金费率计。
+// 年化值仅用于展示, 检测器对永续合约按未年化溢价设下限 (见 BasisConfig.MinPerpPremium)
+const perpFundingPeriodsPerYear = 365 * 3
+
+// CalculateBasis 计算永续合约与各交割合约相对现货指数的基差序列
+// 序列按K线开盘时间与指数K线对齐, 缺失指数价格的时间点会被跳过。
+// 永续合约基差按资金费率周期年化, 交割合约基差按距交割的剩余时间年化。
+func CalculateBasis(symbol string, perp []models.KlineData, index []models.KlineData, deliveries []models.DeliveryContract) []models.BasisSeries {
+	indexByTime := make(map[int64]float64, len(index))
+	for _, k := range index {
+		if k.Close > 0 {
+			indexByTime[k.Timestamp] = k.Close
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	build := func(klines []models.KlineData, annualize func(ts int64) float64) []models.BasisPoint {
+		var points []models.BasisPoint
+		for _, k := range klines {
+			indexPrice, ok := indexByTime[k.Timestamp]
+			if !ok || k.Close <= 0 {
+				continue
+			}
+			basis := (k.Close - indexPrice) / indexPrice
+			points = append(points, models.BasisPoint{
+				Timestamp:  k.Timestamp,
+				Price:      k.Close,
+				IndexPrice: indexPrice,
+				Basis:      basis,
+				Annualized: basis * annualize(k.Timestamp),
+			})
+		}
+		return points
 	}
 
-	var ois []models.BinanceOI
-	if err := json.Unmarshal(body, &ois); err != nil {
-		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	series := []models.BasisSeries{{
+		Symbol:       symbol,
+		ContractType: "PERPETUAL",
+		Points:       build(perp, func(int64) float64 { return perpFundingPeriodsPerYear }),
+	}}
+	for _, d := range deliveries {
+		deliveryTime := d.DeliveryTime
+		series = append(series, models.BasisSeries{
+			Symbol:       d.Symbol,
+			ContractType: d.ContractType,
+			DeliveryTime: deliveryTime,
+			Points: build(d.Klines, func(ts int64) float64 {
+				days := float64(deliveryTime-ts) / float64(24*time.Hour/time.Millisecond)
+				if days < 1 {
+					days = 1 // 临近交割时避免年化值发散
+				}
+				return 365 / days
+			}),
+		})
 	}
-	return ois, nil
+	return series
 }
 
-func getGlobalLongShortAccountRatio(symbol, period string, limit int) ([]models.GlobalLongShortRatio, error) {
-	// ... implementation remains the same ...
-	url := fmt.Sprintf("https://fapi.binance.com/futures/data/globalLongShortAccountRatio?symbol=%s&period=%s&limit=%d", symbol, period, limit)
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
+// DepthRanges 是计算累计流动性时使用的距中间价范围 (%)
+var DepthRanges = []float64{0.5, 1, 2}
+
+// OrderBookMidPrice 返回最优买卖价的中间价, 订单簿为空时返回 0
+func OrderBookMidPrice(book *models.OrderBook) float64 {
+	if book == nil || len(book.Bids) == 0 || len(book.Asks) == 0 {
+		return 0
 	}
-	defer resp.Body.Close()
+	return (book.Bids[0].Price + book.Asks[0].Price) / 2
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// CalculateDepthLiquidity 计算中间价上下各范围内的累计买/卖挂单名义价值
+// 快照深度不足以覆盖某一范围时, 该范围只统计快照内的挂单。
+func CalculateDepthLiquidity(book *models.OrderBook, rangesPercent []float64) []models.DepthLiquidity {
+	mid := OrderBookMidPrice(book)
+	if mid == 0 {
+		return nil
+	}
+
+	liquidity := make([]models.DepthLiquidity, len(rangesPercent))
+	for i, r := range rangesPercent {
+		liquidity[i].RangePercent = r
+		lower, upper := mid*(1-r/100), mid*(1+r/100)
+		for _, l := range book.Bids {
+			if l.Price < lower {
+				break
+			}
+			liquidity[i].BidNotional += l.Price * l.Quantity
+		}
+		for _, l := range book.Asks {
+			if l.Price > upper {
+				break
+			}
+			liquidity[i].AskNotional += l.Price * l.Quantity
+		}
+	}
+	return liquidity
+}
+
+// TimeRange 是毫秒时间戳的左闭右开区间 [From, To)
+type TimeRange struct {
+	From, To int64
+}
+
+// overlaps 判断区间与 [from, to] 是否有交集
+func (r TimeRange) overlaps(from, to int64) bool {
+	return r.From <= to && r.To > from
+}
+
+// AggregateLiquidations 将强平订单按K线周期聚合为多/空强平名义价值
+// 返回的序列与 klines 一一对应; 早于第一根K线的订单会被忽略。
+// gaps 是强平订单没有被完整采集的时间段 (例如流断开期间): 与任一 gap 有交集的K线周期
+// 没有完整数据, 标记为 Unknown 而不是按零强平处理。gaps 为空表示所有周期都已完整采集。
+func AggregateLiquidations(orders []models.ForceOrder, klines []models.KlineData, gaps []TimeRange) []models.LiquidationData {
+	liqs := make([]models.LiquidationData, len(klines))
+	for i, k := range klines {
+		liqs[i] = models.LiquidationData{Symbol: k.Symbol, Timestamp: k.Timestamp}
+		end := k.CloseTime
+		if end < k.Timestamp {
+			end = k.Timestamp
+		}
+		for _, g := range gaps {
+			if g.overlaps(k.Timestamp, end) {
+				liqs[i].Unknown = true
+				break
+			}
+		}
+	}
+	if len(klines) == 0 {
+		return liqs
 	}
 
-	var ratios []models.GlobalLongShortRatio
-	if err := json.Unmarshal(body, &ratios); err != nil {
-		return nil, fmt.Errorf("json unmarshal error: %w, body: %s", err, string(body))
+	for _, o := range orders {
+		// 找到订单所属的K线: 最后一根开盘时间不晚于订单时间的K线
+		idx := sort.Search(len(klines), func(i int) bool { return klines[i].Timestamp > o.Time }) - 1
+		if idx < 0 {
+			continue
+		}
+		if closeTime := klines[idx].CloseTime; closeTime > 0 && o.Time > closeTime {
+			continue // 属于最后一根K线之后 (例如未收盘K线) 的订单
+		}
+
+		qty, _ := strconv.ParseFloat(o.ExecutedQty, 64)
+		if qty == 0 {
+			qty, _ = strconv.ParseFloat(o.OrigQty, 64)
+		}
+		price, _ := strconv.ParseFloat(o.AveragePrice, 64)
+		if price == 0 {
+			price, _ = strconv.ParseFloat(o.Price, 64)
+		}
+
+		switch o.Side {
+		case "SELL": // 多头被强平
+			liqs[idx].LongNotional += qty * price
+		case "BUY": // 空头被强平
+			liqs[idx].ShortNotional += qty * price
+		}
+	}
+	return liqs
+}
+
+// Target 表示在某一交易所上监控的交易对
+type Target struct {
+	Symbol string
+	Source MarketDataSource
+}
+
+// SymbolResult 是单个交易对的抓取与分析结果
+type SymbolResult struct {
+	Symbol   string
+	Exchange string
+	Data     MarketData
+	Signals  []models.Signal
+	Err      error
+}
+
+// AnalyzeSymbols 通过最多 workers 个 goroutine 的工作池获取并分析 targets
+// 结果按 targets 的顺序返回而与完成顺序无关, 以便调用方按确定的顺序输出。
+// opts 会传给 FetchMarketData。
+func AnalyzeSymbols(targets []Target, interval string, limit, workers int, opts AlignOptions) []SymbolResult {
+	results := make([]SymbolResult, len(targets))
+	if workers < 1 {
+		workers = 1
+	}
+
+	// 为每个交易对预留其后同一数据源上尚未开始的交易对的必需序列权重,
+	// 使预算紧张时可选序列让位, 而不是让后面的交易对整个失败
+	reserves := make([]int, len(targets))
+	later := make(map[MarketDataSource]int)
+	for i := len(targets) - 1; i >= 0; i-- {
+		wb, ok := targets[i].Source.(WeightBudget)
+		if !ok {
+			continue
+		}
+		required, _ := wb.FetchWeight(limit + 1)
+		reserves[i] = later[targets[i].Source]
+		later[targets[i].Source] += required
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(targets); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = analyzeTarget(targets[i], interval, limit, reserves[i], opts)
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// analyzeTarget 获取并分析单个 target
+// panic (例如新上线交易对的异常响应) 会被转为该 target 的错误, 避免一个交易对中断整轮运行。
+func analyzeTarget(t Target, interval string, limit, reserve int, opts AlignOptions) (res SymbolResult) {
+	res = SymbolResult{Symbol: t.Symbol, Exchange: t.Source.Exchange()}
+	defer func() {
+		if r := recover(); r != nil {
+			res.Signals = nil
+			res.Err = fmt.Errorf("panic while analyzing %s: %v", t.Symbol, r)
+		}
+	}()
+
+	res.Data, res.Err = fetchMarketData(t.Source, t.Symbol, interval, limit, reserve, opts)
+	if res.Err == nil {
+		res.Signals = Analyze(res.Data)
+	}
+	return res
+}
+
+// ParseInterval 解析 "15m"、"4h" 或 "1d" 这样的币安周期名称
+func ParseInterval(interval string) (time.Duration, error) {
+	if len(interval) < 2 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid interval %q", interval)
+	}
+	switch interval[len(interval)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute, nil
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	case 'w':
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
 	}
-	return ratios, nil
+	return 0, fmt.Errorf("invalid interval %q", interval)
 }
@@ -0,0 +1,540 @@
+// Package stream runs the monitor as a long-running process on top of the
+// Binance USDⓈ-M combined WebSocket streams, so each symbol is analyzed the
+// moment a candle closes instead of on the next cron tick.
+//
+// For every symbol the Streamer subscribes to <symbol>@kline_<interval>,
+// <symbol>@markPrice and <symbol>@forceOrder and keeps a rolling window of
+// closed candles, the latest mark price and the liquidations seen within the
+// window. When a candle closes the symbol is analyzed on the market data
+// fetched over REST, with the latest mark price and the liquidations from the
+// stream laid over it. Liquidations are only available from the stream, so
+// candles that overlap a time the stream was not connected count as unknown
+// to the liquidation detector. These analyses run on a bounded pool of
+// workers (see SetWorkers), and a symbol whose analysis is still queued is
+// not queued again.
+//
+// After a disconnect the Streamer reconnects with backoff, resubscribes and
+// fills the kline gap over REST. Each symbol that gained candles while
+// offline is then analyzed once, on its newest closed candle; the candles in
+// between only enter the history.
+package stream
+
+import (
+	"binance-monitor/models"
+	"binance-monitor/strategy"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultURL is the production USDⓈ-M combined stream endpoint.
+const DefaultURL = "wss://fstream.binance.com/stream"
+
+const (
+	// readTimeout drops a silent connection. Mark prices arrive every 3s, so
+	// anything longer means the connection is dead.
+	readTimeout = 2 * time.Minute
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+	// stableConnection resets the reconnect backoff once a connection lasted
+	// this long.
+	stableConnection = time.Minute
+)
+
+// DefaultWorkers is the default number of concurrent analyses.
+const DefaultWorkers = 4
+
+// Handler receives the analysis of a symbol after one of its candles closed.
+// Calls are serialized, so a Handler may send notifications without locking.
+type Handler func(strategy.SymbolResult)
+
+// Streamer streams and analyzes a fixed set of Binance symbols.
+type Streamer struct {
+	url      string
+	source   strategy.MarketDataSource
+	symbols  []string
+	interval string
+	period   time.Duration
+	limit    int
+	handler  Handler
+	workers  int
+	align    strategy.AlignOptions
+
+	mu      sync.Mutex
+	windows map[string]*window
+	// jobs holds symbols waiting for analysis; queued marks them so each
+	// symbol is queued at most once and sending on jobs never blocks.
+	jobs   chan string
+	queued map[string]bool
+	// liqGaps are the times the forceOrder streams were not subscribed,
+	// oldest first. The last one is open (To is math.MaxInt64) while
+	// disconnected.
+	liqGaps   []strategy.TimeRange
+	handlerMu sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// window is the in-memory state of one symbol.
+type window struct {
+	klines  []models.KlineData // closed candles, oldest first, at most limit
+	premium *models.PremiumIndex
+	orders  []models.ForceOrder // liquidations since the oldest kline
+}
+
+// New creates a Streamer. source is used for the initial load, for the REST
+// series refreshed on every candle close and for gap-filling after
+// reconnects; it is normally a binance.Client.
+func New(source strategy.MarketDataSource, symbols []string, interval string, limit int, handler Handler) (*Streamer, error) {
+	period, err := strategy.ParseInterval(interval)
+	if err != nil {
+		return nil, err
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("no symbols to stream")
+	}
+
+	s := &Streamer{
+		url:      DefaultURL,
+		source:   source,
+		interval: interval,
+		period:   period,
+		limit:    limit,
+		handler:  handler,
+		workers:  DefaultWorkers,
+		align:    strategy.DefaultAlignOptions(),
+		windows:  make(map[string]*window, len(symbols)),
+		jobs:     make(chan string, len(symbols)),
+		queued:   make(map[string]bool, len(symbols)),
+		liqGaps:  []strategy.TimeRange{{From: math.MinInt64, To: math.MaxInt64}},
+	}
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		s.symbols = append(s.symbols, symbol)
+		s.windows[symbol] = &window{}
+	}
+	return s, nil
+}
+
+// SetURL points the Streamer at a different stream endpoint, e.g. the testnet.
+func (s *Streamer) SetURL(url string) {
+	s.url = url
+}
+
+// SetWorkers sets how many symbols are analyzed concurrently. Each analysis
+// refreshes the REST series of its symbol, so this bounds the request burst
+// after a candle close. It must be called before Run.
+func (s *Streamer) SetWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	s.workers = n
+}
+
+// Run streams until ctx is cancelled, reconnecting whenever the connection
+// drops. Run may only be called once. It returns ctx.Err() after the running
+// analyses have finished; queued ones are dropped.
+func (s *Streamer) Run(ctx context.Context) error {
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go s.worker(ctx)
+	}
+	defer func() {
+		close(s.jobs)
+		s.wg.Wait()
+	}()
+
+	delay := minReconnectDelay
+	first := true
+	for {
+		// The first fill only loads history; later fills analyze candles
+		// that closed while disconnected.
+		s.fill(!first)
+		first = false
+
+		started := time.Now()
+		err := s.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(started) > stableConnection {
+			delay = minReconnectDelay
+		}
+		log.Printf("stream disconnected: %v; reconnecting in %s", err, delay)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// streams returns the combined stream names of all symbols.
+func (s *Streamer) streams() []string {
+	var names []string
+	for _, symbol := range s.symbols {
+		lower := strings.ToLower(symbol)
+		names = append(names, lower+"@kline_"+s.interval, lower+"@markPrice", lower+"@forceOrder")
+	}
+	return names
+}
+
+// session connects, subscribes and processes messages until the connection
+// fails or ctx is cancelled.
+func (s *Streamer) session(ctx context.Context) error {
+	dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	conn, err := Dial(dialCtx, s.url)
+	cancel()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock ReadMessage when ctx is cancelled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	sub, _ := json.Marshal(map[string]interface{}{
+		"method": "SUBSCRIBE",
+		"params": s.streams(),
+		"id":     time.Now().UnixNano(),
+	})
+	if err := conn.WriteText(sub); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	log.Printf("stream connected, subscribed to %d streams", len(s.streams()))
+	s.subscribed(time.Now())
+	// Liquidations are missed from the last message on, which is up to
+	// readTimeout before the connection is found dead.
+	lastMessage := time.Now()
+	defer func() { s.unsubscribed(lastMessage) }()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(readTimeout))
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		lastMessage = time.Now()
+		if err := s.handleMessage(msg); err != nil {
+			log.Printf("ignoring stream message: %v", err)
+		}
+	}
+}
+
+// subscribed closes the open liquidation gap at the subscription time and
+// forgets gaps that ended before any window could still hold their candles.
+func (s *Streamer) subscribed(at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := at.UnixMilli()
+	if n := len(s.liqGaps); n > 0 && s.liqGaps[n-1].To == math.MaxInt64 {
+		s.liqGaps[n-1].To = now
+	}
+	horizon := now - int64(s.limit+1)*s.period.Milliseconds()
+	kept := s.liqGaps[:0]
+	for _, g := range s.liqGaps {
+		if g.To > horizon {
+			kept = append(kept, g)
+		}
+	}
+	s.liqGaps = kept
+}
+
+// unsubscribed opens a liquidation gap at the given time.
+func (s *Streamer) unsubscribed(at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.liqGaps = append(s.liqGaps, strategy.TimeRange{From: at.UnixMilli(), To: math.MaxInt64})
+}
+
+// combinedMessage is the envelope of a combined stream event. Subscription
+// acknowledgements have no stream name.
+type combinedMessage struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type klineEvent struct {
+	Symbol string `json:"s"`
+	Kline  struct {
+		StartTime           int64  `json:"t"`
+		CloseTime           int64  `json:"T"`
+		Open                string `json:"o"`
+		High                string `json:"h"`
+		Low                 string `json:"l"`
+		Close               string `json:"c"`
+		Volume              string `json:"v"`
+		TradeCount          int64  `json:"n"`
+		Closed              bool   `json:"x"`
+		QuoteVolume         string `json:"q"`
+		TakerBuyVolume      string `json:"V"`
+		TakerBuyQuoteVolume string `json:"Q"`
+	} `json:"k"`
+}
+
+type markPriceEvent struct {
+	EventTime            int64  `json:"E"`
+	Symbol               string `json:"s"`
+	MarkPrice            string `json:"p"`
+	IndexPrice           string `json:"i"`
+	EstimatedSettlePrice string `json:"P"`
+	FundingRate          string `json:"r"`
+	NextFundingTime      int64  `json:"T"`
+}
+
+type forceOrderEvent struct {
+	Order struct {
+		Symbol       string `json:"s"`
+		Side         string `json:"S"`
+		OrigQty      string `json:"q"`
+		Price        string `json:"p"`
+		AveragePrice string `json:"ap"`
+		Status       string `json:"X"`
+		FilledQty    string `json:"z"`
+		Time         int64  `json:"T"`
+	} `json:"o"`
+}
+
+// handleMessage applies one stream event to the symbol's window.
+func (s *Streamer) handleMessage(msg []byte) error {
+	var m combinedMessage
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return fmt.Errorf("json unmarshal error: %w", err)
+	}
+	at := strings.IndexByte(m.Stream, '@')
+	if at < 0 {
+		return nil // subscription acknowledgement
+	}
+	symbol, kind := strings.ToUpper(m.Stream[:at]), m.Stream[at+1:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.windows[symbol]
+	if !ok {
+		return fmt.Errorf("event for unknown symbol %s", symbol)
+	}
+
+	switch {
+	case strings.HasPrefix(kind, "kline_"):
+		var e klineEvent
+		if err := json.Unmarshal(m.Data, &e); err != nil {
+			return fmt.Errorf("invalid kline event: %w", err)
+		}
+		if !e.Kline.Closed {
+			return nil
+		}
+		k, err := parseKlineEvent(symbol, e)
+		if err != nil {
+			return err
+		}
+		if s.mergeLocked(w, []models.KlineData{k}) {
+			s.analyze(symbol)
+		}
+	case kind == "markPrice":
+		var e markPriceEvent
+		if err := json.Unmarshal(m.Data, &e); err != nil {
+			return fmt.Errorf("invalid mark price event: %w", err)
+		}
+		w.premium = &models.PremiumIndex{
+			Symbol:               symbol,
+			MarkPrice:            e.MarkPrice,
+			IndexPrice:           e.IndexPrice,
+			EstimatedSettlePrice: e.EstimatedSettlePrice,
+			LastFundingRate:      e.FundingRate,
+			NextFundingTime:      e.NextFundingTime,
+			Time:                 e.EventTime,
+		}
+	case kind == "forceOrder":
+		var e forceOrderEvent
+		if err := json.Unmarshal(m.Data, &e); err != nil {
+			return fmt.Errorf("invalid force order event: %w", err)
+		}
+		o := e.Order
+		w.orders = append(w.orders, models.ForceOrder{
+			Symbol:       symbol,
+			Price:        o.Price,
+			OrigQty:      o.OrigQty,
+			ExecutedQty:  o.FilledQty,
+			AveragePrice: o.AveragePrice,
+			Status:       o.Status,
+			Side:         o.Side,
+			Time:         o.Time,
+		})
+	}
+	return nil
+}
+
+// parseKlineEvent converts a closed kline event into KlineData.
+func parseKlineEvent(symbol string, e klineEvent) (models.KlineData, error) {
+	fields := []string{e.Kline.Open, e.Kline.High, e.Kline.Low, e.Kline.Close, e.Kline.Volume, e.Kline.QuoteVolume, e.Kline.TakerBuyVolume, e.Kline.TakerBuyQuoteVolume}
+	f := make([]float64, len(fields))
+	for i, field := range fields {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return models.KlineData{}, fmt.Errorf("invalid kline event field %d: %w", i, err)
+		}
+		f[i] = v
+	}
+	return models.KlineData{
+		Symbol:              symbol,
+		Timestamp:           e.Kline.StartTime,
+		CloseTime:           e.Kline.CloseTime,
+		Open:                f[0],
+		High:                f[1],
+		Low:                 f[2],
+		Close:               f[3],
+		Volume:              f[4],
+		QuoteVolume:         f[5],
+		TradeCount:          e.Kline.TradeCount,
+		TakerBuyVolume:      f[6],
+		TakerBuyQuoteVolume: f[7],
+	}, nil
+}
+
+// mergeLocked merges closed klines into the window, keeps the newest limit
+// of them and drops liquidations older than the window. It reports whether
+// a candle newer than the previous latest one was added. s.mu must be held.
+func (s *Streamer) mergeLocked(w *window, klines []models.KlineData) bool {
+	var latest int64
+	if n := len(w.klines); n > 0 {
+		latest = w.klines[n-1].Timestamp
+	}
+
+	byTime := make(map[int64]models.KlineData, len(w.klines)+len(klines))
+	for _, k := range w.klines {
+		byTime[k.Timestamp] = k
+	}
+	for _, k := range klines {
+		byTime[k.Timestamp] = k
+	}
+	merged := make([]models.KlineData, 0, len(byTime))
+	for _, k := range byTime {
+		merged = append(merged, k)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Timestamp < merged[j].Timestamp })
+	if len(merged) > s.limit {
+		merged = merged[len(merged)-s.limit:]
+	}
+	w.klines = merged
+
+	if len(merged) > 0 {
+		oldest := merged[0].Timestamp
+		kept := w.orders[:0]
+		for _, o := range w.orders {
+			if o.Time >= oldest {
+				kept = append(kept, o)
+			}
+		}
+		w.orders = kept
+		return merged[len(merged)-1].Timestamp > latest
+	}
+	return false
+}
+
+// fill loads closed klines over REST into every window, plus the latest
+// premium index where the source provides it. With analyzeNew set, symbols
+// that gained a closed candle are analyzed.
+func (s *Streamer) fill(analyzeNew bool) {
+	for _, symbol := range s.symbols {
+		// One extra kline, since the newest one is usually still open.
+		klines, err := s.source.Klines(symbol, s.interval, s.limit+1)
+		if err != nil {
+			log.Printf("failed to backfill %s klines: %v", symbol, err)
+			continue
+		}
+		closed, _ := strategy.SplitClosedKlines(klines, s.period, s.align.Now())
+
+		var premium *models.PremiumIndex
+		if fs, ok := s.source.(strategy.FundingSource); ok {
+			if premium, err = fs.PremiumIndex(symbol); err != nil {
+				log.Printf("failed to backfill %s premium index: %v", symbol, err)
+			}
+		}
+
+		s.mu.Lock()
+		w := s.windows[symbol]
+		if premium != nil {
+			w.premium = premium
+		}
+		added := s.mergeLocked(w, closed)
+		if added && analyzeNew {
+			s.analyze(symbol)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// analyze queues symbol for analysis unless it is already queued, so the
+// read loop keeps answering pings while REST series are refreshed. s.mu must
+// be held.
+func (s *Streamer) analyze(symbol string) {
+	if s.queued[symbol] {
+		return
+	}
+	s.queued[symbol] = true
+	s.jobs <- symbol
+}
+
+// worker analyzes queued symbols until jobs is closed. The window is
+// snapshotted when the job is taken, so a job that waited in the queue
+// analyzes the newest closed candle.
+func (s *Streamer) worker(ctx context.Context) {
+	defer s.wg.Done()
+	for symbol := range s.jobs {
+		s.mu.Lock()
+		delete(s.queued, symbol)
+		w := s.windows[symbol]
+		orders := append([]models.ForceOrder(nil), w.orders...)
+		gaps := append([]strategy.TimeRange(nil), s.liqGaps...)
+		if len(w.klines) > 0 {
+			// mergeLocked dropped the liquidations older than the window.
+			gaps = append(gaps, strategy.TimeRange{From: math.MinInt64, To: w.klines[0].Timestamp})
+		}
+		var premium *models.PremiumIndex
+		if w.premium != nil {
+			p := *w.premium
+			premium = &p
+		}
+		s.mu.Unlock()
+		if ctx.Err() != nil {
+			continue
+		}
+
+		res := strategy.SymbolResult{Symbol: symbol, Exchange: s.source.Exchange()}
+		res.Data, res.Err = strategy.FetchMarketData(s.source, symbol, s.interval, s.limit, s.align)
+		if res.Err == nil {
+			// Keep the REST klines, so every series stays on the grid
+			// FetchMarketData aligned them to, and lay the stream data over
+			// them: the latest mark price and the liquidations seen on the
+			// stream, aggregated onto that grid.
+			if premium != nil {
+				res.Data.PremiumIndex = premium
+			}
+			res.Data.Liquidations = strategy.AggregateLiquidations(orders, res.Data.Klines, gaps)
+			res.Signals = strategy.Analyze(res.Data)
+		}
+
+		s.handlerMu.Lock()
+		s.handler(res)
+		s.handlerMu.Unlock()
+	}
+}
@@ -0,0 +1,324 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// frame is a frame as seen by the test server.
+type frame struct {
+	fin     bool
+	opcode  byte
+	masked  bool
+	payload []byte
+}
+
+// serve starts a WebSocket server that completes the handshake and hands the
+// raw connection to script. It returns the ws:// URL and a channel closed
+// once script returns.
+func serve(t *testing.T, script func(conn net.Conn, br *bufio.Reader)) (string, <-chan struct{}) {
+	t.Helper()
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		key := r.Header.Get("Sec-Websocket-Key")
+		if r.Header.Get("Sec-Websocket-Version") != "13" || key == "" {
+			t.Errorf("bad handshake headers: %v", r.Header)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		sum := sha1.Sum([]byte(key + websocketGUID))
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+		if err := rw.Flush(); err != nil {
+			t.Errorf("write handshake: %v", err)
+			return
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		script(conn, rw.Reader)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), done
+}
+
+// dial connects to url and closes the connection at the end of the test.
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, url)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return c
+}
+
+// writeServerFrame writes an unmasked frame, as servers must.
+func writeServerFrame(w io.Writer, fin bool, opcode byte, payload []byte) error {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+	buf := []byte{b0}
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, 126, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+// readClientFrame reads a frame sent by the client and unmasks it.
+func readClientFrame(br *bufio.Reader) (frame, error) {
+	var f frame
+	var header [2]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return f, err
+	}
+	f.fin = header[0]&0x80 != 0
+	f.opcode = header[0] & 0x0F
+	f.masked = header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			return f, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			return f, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	var mask [4]byte
+	if f.masked {
+		if _, err := io.ReadFull(br, mask[:]); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(br, f.payload); err != nil {
+		return f, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// payloadOf returns n bytes of a repeating pattern.
+func payloadOf(n int) []byte {
+	return bytes.Repeat([]byte("0123456789"), n/10+1)[:n]
+}
+
+func TestReadMessageLengths(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"7-bit length", 125},
+		{"16-bit length", 126},
+		{"16-bit max", 0xFFFF},
+		{"64-bit length", 0x10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := payloadOf(tt.size)
+			url, done := serve(t, func(conn net.Conn, br *bufio.Reader) {
+				if err := writeServerFrame(conn, true, opText, want); err != nil {
+					t.Errorf("write: %v", err)
+				}
+				io.Copy(io.Discard, br) // keep the connection open until the client closes it
+			})
+			c := dial(t, url)
+
+			got, err := c.ReadMessage()
+			if err != nil {
+				t.Fatalf("ReadMessage: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got %d bytes, want %d", len(got), len(want))
+			}
+			c.Close()
+			<-done
+		})
+	}
+}
+
+func TestReadMessageFragmentedWithPing(t *testing.T) {
+	pong := make(chan frame, 1)
+	url, done := serve(t, func(conn net.Conn, br *bufio.Reader) {
+		// A control frame may be interleaved with the fragments of a message.
+		writeServerFrame(conn, false, opText, []byte("hel"))
+		writeServerFrame(conn, true, opPing, []byte("keepalive"))
+		writeServerFrame(conn, false, opContinuation, []byte("lo, "))
+		writeServerFrame(conn, true, opContinuation, []byte("world"))
+
+		f, err := readClientFrame(br)
+		if err != nil {
+			t.Errorf("read pong: %v", err)
+			return
+		}
+		pong <- f
+	})
+	c := dial(t, url)
+
+	got, err := c.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if string(got) != "hello, world" {
+		t.Errorf("message = %q, want %q", got, "hello, world")
+	}
+
+	<-done
+	select {
+	case f := <-pong:
+		if f.opcode != opPong || !f.fin || !f.masked || string(f.payload) != "keepalive" {
+			t.Errorf("pong = %+v, want a masked pong echoing %q", f, "keepalive")
+		}
+	default:
+		t.Fatal("no pong received")
+	}
+}
+
+func TestWriteTextMasked(t *testing.T) {
+	sizes := []int{0, 5, 125, 126, 0xFFFF, 0x10000}
+	frames := make(chan frame, len(sizes))
+	url, done := serve(t, func(conn net.Conn, br *bufio.Reader) {
+		for range sizes {
+			f, err := readClientFrame(br)
+			if err != nil {
+				t.Errorf("read frame: %v", err)
+				return
+			}
+			frames <- f
+		}
+	})
+	c := dial(t, url)
+
+	for _, size := range sizes {
+		if err := c.WriteText(payloadOf(size)); err != nil {
+			t.Fatalf("WriteText(%d bytes): %v", size, err)
+		}
+	}
+	<-done
+	close(frames)
+
+	i := 0
+	for f := range frames {
+		want := payloadOf(sizes[i])
+		if !f.masked {
+			t.Errorf("frame %d is not masked", i)
+		}
+		if !f.fin || f.opcode != opText {
+			t.Errorf("frame %d: fin %v, opcode %#x, want a final text frame", i, f.fin, f.opcode)
+		}
+		if !bytes.Equal(f.payload, want) {
+			t.Errorf("frame %d: payload of %d bytes does not match the %d sent", i, len(f.payload), len(want))
+		}
+		i++
+	}
+	if i != len(sizes) {
+		t.Errorf("server received %d frames, want %d", i, len(sizes))
+	}
+}
+
+func TestReadMessageClose(t *testing.T) {
+	echo := make(chan frame, 1)
+	closePayload := []byte{0x03, 0xE8} // status 1000, normal closure
+	url, done := serve(t, func(conn net.Conn, br *bufio.Reader) {
+		writeServerFrame(conn, true, opClose, closePayload)
+		f, err := readClientFrame(br)
+		if err != nil {
+			t.Errorf("read close: %v", err)
+			return
+		}
+		echo <- f
+	})
+	c := dial(t, url)
+
+	if _, err := c.ReadMessage(); !errors.Is(err, errClosed) {
+		t.Fatalf("ReadMessage error = %v, want errClosed", err)
+	}
+	<-done
+	select {
+	case f := <-echo:
+		if f.opcode != opClose || !f.masked || !bytes.Equal(f.payload, closePayload) {
+			t.Errorf("close reply = %+v, want a masked close echoing the status", f)
+		}
+	default:
+		t.Fatal("close frame was not acknowledged")
+	}
+}
+
+func TestReadMessageRejectsOversizedFrame(t *testing.T) {
+	url, done := serve(t, func(conn net.Conn, br *bufio.Reader) {
+		header := []byte{0x80 | opBinary, 127}
+		conn.Write(binary.BigEndian.AppendUint64(header, maxMessageSize+1))
+		io.Copy(io.Discard, br)
+	})
+	c := dial(t, url)
+
+	if _, err := c.ReadMessage(); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("ReadMessage error = %v, want a size error", err)
+	}
+	c.Close()
+	<-done
+}
+
+func TestDialRejectsInvalidAccept(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: invalid\r\n\r\n")
+		rw.Flush()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if c, err := Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")); err == nil {
+		c.Close()
+		t.Fatal("Dial succeeded with an invalid Sec-WebSocket-Accept")
+	}
+}
@@ -1,18 +1,57 @@
+//go:build js && wasm
 
 package main
 
 import (
+	"binance-monitor/binance"
+	"binance-monitor/bybit"
 	"binance-monitor/cache"
 	"binance-monitor/gemini"
 	"binance-monitor/lark"
 	"binance-monitor/models"
+	"binance-monitor/okx"
 	"binance-monitor/strategy"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"syscall/js"
 )
 
+const (
+	// defaultWeightBudget leaves half of Binance's 2400/min weight limit for
+	// other clients sharing the egress IP.
+	defaultWeightBudget = 1200
+	// defaultFetchConcurrency is the number of symbols fetched in parallel.
+	defaultFetchConcurrency = 4
+)
+
+// cacheTTL is how long a sent signal suppresses repeats of the same type and
+// direction, in seconds. Critical signals expire sooner so a persisting
+// critical condition is re-alerted; info signals are repeated the least.
+var cacheTTL = map[models.Severity]int{
+	models.SeverityInfo:     4 * 3600,
+	models.SeverityWarn:     3600,
+	models.SeverityCritical: 1800,
+}
+
+// router decides where signals are sent.
+type router struct {
+	bot *lark.Bot
+	// criticalBot receives critical signals instead of bot when set.
+	criticalBot *lark.Bot
+	// minSeverity drops less severe signals before AI analysis and sending.
+	minSeverity models.Severity
+}
+
+// botFor returns the bot a signal is sent to.
+func (r router) botFor(signal models.Signal) *lark.Bot {
+	if signal.Severity == models.SeverityCritical && r.criticalBot != nil {
+		return r.criticalBot
+	}
+	return r.bot
+}
+
 func runCheck() {
 	fmt.Println("开始执行检查...")
 
@@ -24,27 +63,85 @@ func runCheck() {
 	aiModel := os.Getenv("AI_MODEL_NAME")
 	kvBinding := "SIGNAL_CACHE" // The binding name from wrangler.toml
 
-	if larkWebhookURL == "" || symbolsStr == "" {
-		fmt.Println("错误: 缺少环境变量 LARK_WEBHOOK_URL 或 SYMBOLS")
+	// Optional: override the exchange API base URLs (proxy, testnet, mock server)
+	binanceBaseURL := os.Getenv("BINANCE_BASE_URL")
+	binanceSpotBaseURL := os.Getenv("BINANCE_SPOT_BASE_URL")
+	okxBaseURL := os.Getenv("OKX_BASE_URL")
+	bybitBaseURL := os.Getenv("BYBIT_BASE_URL")
+	// Optional: max request weight one run may spend across all symbols
+	weightBudget := defaultWeightBudget
+	if v, err := strconv.Atoi(os.Getenv("BINANCE_WEIGHT_BUDGET")); err == nil {
+		weightBudget = v
+	}
+	// Optional: interval, lookback and detector thresholds, globally or per symbol
+	cfg, err := strategy.ConfigFromEnv(os.Getenv)
+	if err != nil {
+		fmt.Printf("错误: 检测器配置无效: %v\n", err)
 		return
 	}
-
-	bot := lark.NewBot(larkWebhookURL)
-	symbols := strings.Split(symbolsStr, ",")
-
-	for _, symbol := range symbols {
-		checkSymbol(symbol, bot, aiEndpoint, aiModel, apiKey, kvBinding)
+	strategy.SetConfig(cfg)
+	// Optional: number of symbols fetched in parallel
+	concurrency := defaultFetchConcurrency
+	if v, err := strconv.Atoi(os.Getenv("FETCH_CONCURRENCY")); err == nil && v > 0 {
+		concurrency = v
+	}
+	// Optional: also monitor the top N Binance perpetuals by 24h quote volume
+	universeTopN, _ := strconv.Atoi(os.Getenv("UNIVERSE_TOP_N"))
+	universeInclude := strings.Split(os.Getenv("UNIVERSE_INCLUDE"), ",")
+	universeExclude := strings.Split(os.Getenv("UNIVERSE_EXCLUDE"), ",")
+	// Optional: enable/disable detectors globally or per symbol
+	if err := strategy.DefaultRegistry.Configure(os.Getenv("DETECTORS")); err != nil {
+		fmt.Printf("错误: DETECTORS 配置无效: %v\n", err)
+		return
 	}
 
-	fmt.Println("检查完成。")
-}
+	if larkWebhookURL == "" || (symbolsStr == "" && universeTopN <= 0) {
+		fmt.Println("错误: 缺少环境变量 LARK_WEBHOOK_URL 或 SYMBOLS (或 UNIVERSE_TOP_N)")
+		return
+	}
 
-func checkSymbol(symbol string, bot *lark.Bot, aiEndpoint, aiModel, apiKey, kvBinding string) {
-	const LookbackPeriod = 96
-	const DataInterval = "15m"
-	const CacheTTL = 3600 // 1 hour in seconds
+	routes := router{bot: lark.NewBot(larkWebhookURL), minSeverity: models.SeverityInfo}
+	// Optional: separate webhook for critical signals, e.g. a paging group
+	if webhook := os.Getenv("LARK_CRITICAL_WEBHOOK_URL"); webhook != "" {
+		routes.criticalBot = lark.NewBot(webhook)
+	}
+	// Optional: only send signals of at least this severity
+	if v := os.Getenv("MIN_SEVERITY"); v != "" {
+		if routes.minSeverity, err = models.ParseSeverity(v); err != nil {
+			fmt.Printf("错误: MIN_SEVERITY 无效: %v\n", err)
+			return
+		}
+	}
+	binanceClient := binance.NewClient(nil, binanceBaseURL)
+	binanceClient.SetWeightBudget(weightBudget)
+	binanceClient.SetSpotBaseURL(binanceSpotBaseURL)
+	sources := map[string]strategy.MarketDataSource{
+		binance.Exchange: binanceClient,
+		okx.Exchange:     okx.NewClient(nil, okxBaseURL),
+		bybit.Exchange:   bybit.NewClient(nil, bybitBaseURL),
+	}
 
-	fmt.Printf("正在为 %s 获取市场数据...\n", symbol)
+	targets, err := parseTargets(symbolsStr, sources)
+	if err != nil {
+		fmt.Printf("错误: SYMBOLS 配置无效: %v\n", err)
+		return
+	}
+	if universeTopN > 0 {
+		universe, err := binanceClient.Universe(binance.UniverseOptions{
+			TopN:    universeTopN,
+			Include: universeInclude,
+			Exclude: universeExclude,
+		})
+		if err != nil {
+			fmt.Printf("获取交易对列表失败, 仅监控 SYMBOLS 中的交易对: %v\n", err)
+		} else {
+			targets = addUniverse(targets, universe, binanceClient)
+		}
+	}
+	if len(targets) == 0 {
+		fmt.Println("错误: 没有可监控的交易对")
+		return
+	}
 
 	// Get KV Namespace
 	kv, err := cache.GetKVNamespace(kvBinding)
@@ -52,30 +149,96 @@ func checkSymbol(symbol string, bot *lark.Bot, aiEndpoint, aiModel, apiKey, kvBi
 		fmt.Printf("获取KV命名空间失败: %v。缓存功能将不可用。\n", err)
 	}
 
-	marketData, err := strategy.FetchMarketData(symbol, DataInterval, LookbackPeriod)
-	if err != nil {
-		fmt.Printf("获取 %s 的市场数据失败: %v\n", symbol, err)
-		return
+	fmt.Printf("正在为 %d 个交易对获取市场数据 (并发数 %d)...\n", len(targets), concurrency)
+	results := strategy.AnalyzeSymbols(targets, cfg.Interval, cfg.Lookback, concurrency, strategy.DefaultAlignOptions())
+	// Assets monitored on several venues are also analyzed on their combined
+	// USD open interest and volume.
+	results = append(results, strategy.AggregateResults(results)...)
+
+	// Notifications are sent sequentially in SYMBOLS order so the Lark output
+	// does not interleave.
+	for _, result := range results {
+		checkSymbol(result, kv, routes, aiEndpoint, aiModel, apiKey)
 	}
 
-	signals := strategy.Analyze(marketData)
+	fmt.Printf("检查完成。本次消耗请求权重 %d (预算 %d)。\n", binanceClient.WeightSpent(), weightBudget)
+}
+
+// parseTargets parses the SYMBOLS list. Each entry is a symbol in Binance
+// naming, optionally prefixed with its venue, e.g. "BTCUSDT,okx:ETHUSDT".
+// Entries without a venue are monitored on Binance. An unknown venue is a
+// configuration error, like an invalid DETECTORS rule.
+func parseTargets(symbolsStr string, sources map[string]strategy.MarketDataSource) ([]strategy.Target, error) {
+	var targets []strategy.Target
+	for _, entry := range strings.Split(symbolsStr, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		venue, symbol := binance.Exchange, entry
+		if i := strings.Index(entry, ":"); i >= 0 {
+			venue, symbol = strings.ToLower(entry[:i]), entry[i+1:]
+		}
+		source, ok := sources[venue]
+		if !ok {
+			return nil, fmt.Errorf("unknown venue %q in %q", venue, entry)
+		}
+		targets = append(targets, strategy.Target{Symbol: strings.ToUpper(symbol), Source: source})
+	}
+	return targets, nil
+}
+
+// addUniverse appends the universe symbols that are not already monitored on
+// Binance through SYMBOLS.
+func addUniverse(targets []strategy.Target, universe []string, source strategy.MarketDataSource) []strategy.Target {
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if t.Source.Exchange() == binance.Exchange {
+			seen[t.Symbol] = true
+		}
+	}
+	for _, symbol := range universe {
+		if !seen[symbol] {
+			seen[symbol] = true
+			targets = append(targets, strategy.Target{Symbol: symbol, Source: source})
+		}
+	}
+	return targets
+}
+
+func checkSymbol(result strategy.SymbolResult, kv js.Value, routes router, aiEndpoint, aiModel, apiKey string) {
+	symbol, marketData, signals := result.Symbol, result.Data, result.Signals
+	if result.Err != nil {
+		fmt.Printf("获取 %s (%s) 的市场数据失败: %v\n", symbol, result.Exchange, result.Err)
+		return
+	}
+	for _, warning := range marketData.Warnings {
+		fmt.Printf("%s 的部分数据不可用, 相关检测已跳过: %s\n", symbol, warning)
+	}
 
 	if len(signals) > 0 {
 		fmt.Printf("为 %s 发现 %d 个信号:\n", symbol, len(signals))
 		contextData := strategy.BuildContextData(marketData)
 
 		for _, signal := range signals {
-			// Check cache before sending notification
-			cacheKey := fmt.Sprintf("%s:%s", signal.Symbol, signal.SignalType)
+			if signal.Severity.Rank() < routes.minSeverity.Rank() {
+				fmt.Printf("  - 信号: %s (%s) 低于 MIN_SEVERITY，跳过。\n", signal.SignalType, signal.Severity)
+				continue
+			}
+
+			// Check cache before sending notification. The cache holds the
+			// severity last sent per direction: an opposite signal is new, and
+			// an escalation (e.g. warn -> critical) is sent despite the cache.
+			cacheKey := fmt.Sprintf("%s:%s:%s:%s", signal.Exchange, signal.Symbol, signal.SignalType, signal.Direction)
 			if !kv.IsUndefined() {
-				exists, _ := cache.KeyExists(kv, cacheKey)
-				if exists {
-					fmt.Printf("信号 '%s' 在一小时内已发送过，跳过。\n", cacheKey)
+				sent, exists, _ := cache.GetValue(kv, cacheKey)
+				if exists && models.Severity(sent).Rank() >= signal.Severity.Rank() {
+					fmt.Printf("信号 '%s' 近期已发送过 (%s)，跳过。\n", cacheKey, sent)
 					continue // Skip to the next signal
 				}
 			}
 
-			fmt.Printf("  - 信号: %s, 描述: %s\n", signal.SignalType, signal.Description)
+			fmt.Printf("  - 信号: %s [%s/%s %.2f], 描述: %s\n", signal.SignalType, signal.Direction, signal.Severity, signal.Score, signal.Description)
 
 			if aiEndpoint != "" && aiModel != "" && apiKey != "" {
 				analysis, err := gemini.GetAIAnalysis(aiEndpoint, aiModel, apiKey, signal, contextData)
@@ -86,14 +249,18 @@ func checkSymbol(symbol string, bot *lark.Bot, aiEndpoint, aiModel, apiKey, kvBi
 				}
 			}
 
-			err := bot.SendSignal(signal)
+			err := routes.botFor(signal).SendSignal(signal)
 			if err != nil {
 				fmt.Printf("发送飞书消息失败: %v\n", err)
 			} else {
 				// Cache the signal upon successful sending
 				if !kv.IsUndefined() {
-					cache.SetKey(kv, cacheKey, CacheTTL)
-					fmt.Printf("信号 '%s' 已缓存，有效期 %d 秒。\n", cacheKey, CacheTTL)
+					ttl := cacheTTL[signal.Severity]
+					if ttl == 0 {
+						ttl = cacheTTL[models.SeverityWarn]
+					}
+					cache.SetValue(kv, cacheKey, string(signal.Severity), ttl)
+					fmt.Printf("信号 '%s' 已缓存，有效期 %d 秒。\n", cacheKey, ttl)
 				}
 			}
 		}